@@ -0,0 +1,5 @@
+// Package cborx provides a [CBOR] codec for [the httpx package].
+//
+// [CBOR]: https://www.rfc-editor.org/rfc/rfc8949
+// [the httpx package]: https://godocs.io/git.sr.ht/~jamesponddotco/httpx-go
+package cborx
@@ -0,0 +1,41 @@
+package cborx
+
+import (
+	"fmt"
+	"io"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// MediaType is the media type of CBOR bodies.
+const MediaType string = "application/cbor"
+
+// Codec is an httpx.Codec for CBOR bodies.
+type Codec struct{}
+
+// Compile-time check to ensure Codec implements the httpx.Codec interface.
+var _ httpx.Codec = Codec{}
+
+// MediaTypes returns the CBOR media type.
+func (Codec) MediaTypes() []string {
+	return []string{MediaType}
+}
+
+// Encode writes v as CBOR.
+func (Codec) Encode(w io.Writer, v any) error {
+	if err := cbor.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Decode decodes a CBOR data item into v.
+func (Codec) Decode(r io.Reader, v any) error {
+	if err := cbor.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
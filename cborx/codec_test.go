@@ -0,0 +1,55 @@
+package cborx_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"git.sr.ht/~jamesponddotco/httpx-go/cborx"
+)
+
+type item struct {
+	Name  string
+	Count int
+}
+
+func TestCodec(t *testing.T) {
+	t.Parallel()
+
+	codecs := httpx.NewCodecs(cborx.Codec{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+
+		if _, err := io.Copy(w, r.Body); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	want := item{Name: "widget", Count: 3}
+
+	req, err := codecs.NewRequest(context.Background(), http.MethodPost, server.URL, cborx.MediaType, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := httpx.NewClientWithCache(nil).Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got item
+
+	if err = codecs.ReadBody(resp, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Errorf("ReadBody() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,304 @@
+package httpx
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// _defaultFailureRatio is the failure ratio used when
+	// CircuitBreaker.FailureRatio is zero.
+	_defaultFailureRatio float64 = 0.5
+
+	// _defaultMinRequests is the minimum number of requests used when
+	// CircuitBreaker.MinRequests is zero.
+	_defaultMinRequests int = 10
+)
+
+// CircuitState represents the state of a circuit breaker for a single host.
+type CircuitState int
+
+const (
+	// CircuitClosed is the normal state, in which requests are allowed.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen is the state in which requests fail fast without being sent.
+	CircuitOpen
+
+	// CircuitHalfOpen is the state in which a limited number of trial requests
+	// are allowed to check whether the host has recovered.
+	CircuitHalfOpen
+)
+
+// String returns the string representation of the circuit state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker implements the circuit breaker pattern on a per-host basis,
+// preventing a Client from sending requests to hosts that are failing.
+//
+// A host's circuit opens once the ratio of failed requests within Interval
+// reaches FailureRatio, and no requests are sent to it until Cooldown has
+// passed. After that, up to HalfOpenRequests trial requests are allowed; if
+// they all succeed the circuit closes, otherwise it opens again.
+//
+// Zero FailureRatio, MinRequests and HalfOpenRequests use the defaults of
+// DefaultCircuitBreaker, which should be used to create circuit breakers.
+type CircuitBreaker struct {
+	// circuits holds the circuit for each host.
+	circuits map[string]*circuit
+
+	// FailureRatio is the ratio of failed requests, between 0 and 1, at which
+	// a host's circuit opens. If zero, a default ratio of 0.5 is used.
+	FailureRatio float64
+
+	// MinRequests is the minimum number of requests within Interval before
+	// FailureRatio is evaluated. If zero, a default of 10 requests is used.
+	MinRequests int
+
+	// HalfOpenRequests is the number of trial requests allowed while a circuit
+	// is half-open. All of them must succeed for the circuit to close.
+	HalfOpenRequests int
+
+	// Interval is the period after which the request and failure counts of a
+	// closed circuit are reset.
+	Interval time.Duration
+
+	// Cooldown is how long a circuit stays open before allowing trial
+	// requests.
+	Cooldown time.Duration
+
+	// mu protects circuits.
+	mu sync.Mutex
+}
+
+// circuit holds the state of a circuit breaker for a single host.
+type circuit struct {
+	// windowStart is when the current counting interval started.
+	windowStart time.Time
+
+	// openedAt is when the circuit last opened.
+	openedAt time.Time
+
+	// state is the current state of the circuit.
+	state CircuitState
+
+	// requests is the number of requests in the current interval.
+	requests int
+
+	// failures is the number of failed requests in the current interval.
+	failures int
+
+	// inFlight is the number of trial requests in flight while half-open.
+	inFlight int
+
+	// successes is the number of successful trial requests while half-open.
+	successes int
+}
+
+// DefaultCircuitBreaker returns a CircuitBreaker with sensible defaults.
+func DefaultCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		circuits:         make(map[string]*circuit),
+		FailureRatio:     _defaultFailureRatio,
+		MinRequests:      _defaultMinRequests,
+		HalfOpenRequests: 1,
+		Interval:         60 * time.Second,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// Allow checks if a request to the given host may be sent. It returns a
+// *CircuitOpenError if the host's circuit is open, and otherwise reserves a
+// slot that must be released by calling either Done or Release once the request
+// completes.
+func (b *CircuitBreaker) Allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var (
+		c   = b.circuit(host)
+		now = time.Now()
+	)
+
+	if c.state == CircuitOpen {
+		retryAt := c.openedAt.Add(b.Cooldown)
+		if now.Before(retryAt) {
+			return &CircuitOpenError{
+				Host:    host,
+				RetryAt: retryAt,
+			}
+		}
+
+		c.state = CircuitHalfOpen
+		c.inFlight = 0
+		c.successes = 0
+	}
+
+	if c.state == CircuitHalfOpen {
+		if c.inFlight+c.successes >= b.halfOpenRequests() {
+			return &CircuitOpenError{
+				Host:    host,
+				RetryAt: now.Add(b.Cooldown),
+			}
+		}
+
+		c.inFlight++
+
+		return nil
+	}
+
+	if b.Interval > 0 && now.Sub(c.windowStart) >= b.Interval {
+		c.windowStart = now
+		c.requests = 0
+		c.failures = 0
+	}
+
+	return nil
+}
+
+// Done records the outcome of a request to the given host that was allowed by
+// Allow.
+func (b *CircuitBreaker) Done(host string, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(host)
+
+	switch c.state {
+	case CircuitHalfOpen:
+		if c.inFlight > 0 {
+			c.inFlight--
+		}
+
+		if failed {
+			b.open(c)
+
+			return
+		}
+
+		c.successes++
+
+		if c.successes >= b.halfOpenRequests() {
+			b.close(c)
+		}
+	case CircuitClosed:
+		c.requests++
+
+		if failed {
+			c.failures++
+		}
+
+		if c.requests >= b.minRequests() && float64(c.failures)/float64(c.requests) >= b.failureRatio() {
+			b.open(c)
+		}
+	case CircuitOpen:
+		// A request that was allowed before the circuit opened; nothing to do.
+	}
+}
+
+// Release frees a slot reserved by Allow without recording an outcome, such as
+// when the request was canceled by the caller.
+func (b *CircuitBreaker) Release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(host)
+
+	if c.state == CircuitHalfOpen && c.inFlight > 0 {
+		c.inFlight--
+	}
+}
+
+// State returns the current state of the circuit for the given host.
+func (b *CircuitBreaker) State(host string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[host]
+	if !ok {
+		return CircuitClosed
+	}
+
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= b.Cooldown {
+		return CircuitHalfOpen
+	}
+
+	return c.state
+}
+
+// circuit returns the circuit for the given host, creating it if needed. The
+// caller must hold b.mu.
+func (b *CircuitBreaker) circuit(host string) *circuit {
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+
+	c, ok := b.circuits[host]
+	if !ok {
+		c = &circuit{
+			windowStart: time.Now(),
+		}
+
+		b.circuits[host] = c
+	}
+
+	return c
+}
+
+// open transitions a circuit to the open state. The caller must hold b.mu.
+func (*CircuitBreaker) open(c *circuit) {
+	c.state = CircuitOpen
+	c.openedAt = time.Now()
+	c.inFlight = 0
+	c.successes = 0
+}
+
+// close transitions a circuit to the closed state. The caller must hold b.mu.
+func (*CircuitBreaker) close(c *circuit) {
+	c.state = CircuitClosed
+	c.windowStart = time.Now()
+	c.requests = 0
+	c.failures = 0
+	c.inFlight = 0
+	c.successes = 0
+}
+
+// halfOpenRequests returns the number of trial requests allowed while a
+// circuit is half-open.
+func (b *CircuitBreaker) halfOpenRequests() int {
+	if b.HalfOpenRequests < 1 {
+		return 1
+	}
+
+	return b.HalfOpenRequests
+}
+
+// failureRatio returns the ratio of failed requests at which a circuit opens.
+func (b *CircuitBreaker) failureRatio() float64 {
+	if b.FailureRatio <= 0 {
+		return _defaultFailureRatio
+	}
+
+	return b.FailureRatio
+}
+
+// minRequests returns the minimum number of requests before the failure ratio
+// is evaluated.
+func (b *CircuitBreaker) minRequests() int {
+	if b.MinRequests < 1 {
+		return _defaultMinRequests
+	}
+
+	return b.MinRequests
+}
@@ -0,0 +1,288 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+func TestCircuitBreaker_State(t *testing.T) {
+	t.Parallel()
+
+	const host = "example.com"
+
+	breaker := httpx.DefaultCircuitBreaker()
+	breaker.MinRequests = 2
+	breaker.Cooldown = 50 * time.Millisecond
+
+	steps := []struct {
+		name      string
+		failed    bool
+		wantAllow bool
+		wantState httpx.CircuitState
+	}{
+		{name: "first success", failed: false, wantAllow: true, wantState: httpx.CircuitClosed},
+		{name: "first failure", failed: true, wantAllow: true, wantState: httpx.CircuitOpen},
+		{name: "rejected while open", wantAllow: false, wantState: httpx.CircuitOpen},
+	}
+
+	for _, step := range steps {
+		err := breaker.Allow(host)
+		if (err == nil) != step.wantAllow {
+			t.Fatalf("%s: Allow() error = %v, want allowed %v", step.name, err, step.wantAllow)
+		}
+
+		if err == nil {
+			breaker.Done(host, step.failed)
+		}
+
+		if got := breaker.State(host); got != step.wantState {
+			t.Fatalf("%s: State() = %v, want %v", step.name, got, step.wantState)
+		}
+	}
+
+	time.Sleep(breaker.Cooldown)
+
+	if got := breaker.State(host); got != httpx.CircuitHalfOpen {
+		t.Fatalf("State() after cooldown = %v, want %v", got, httpx.CircuitHalfOpen)
+	}
+
+	if err := breaker.Allow(host); err != nil {
+		t.Fatalf("Allow() trial request error = %v", err)
+	}
+
+	if err := breaker.Allow(host); err == nil {
+		t.Fatal("Allow() second trial request succeeded, want error")
+	}
+
+	breaker.Done(host, false)
+
+	if got := breaker.State(host); got != httpx.CircuitClosed {
+		t.Fatalf("State() after successful trial = %v, want %v", got, httpx.CircuitClosed)
+	}
+}
+
+func TestCircuitBreaker_ZeroValue(t *testing.T) {
+	t.Parallel()
+
+	const host = "example.com"
+
+	breaker := &httpx.CircuitBreaker{Cooldown: time.Minute}
+
+	for i := 0; i < 20; i++ {
+		if err := breaker.Allow(host); err != nil {
+			t.Fatalf("request %d: Allow() error = %v", i, err)
+		}
+
+		breaker.Done(host, false)
+	}
+
+	if got := breaker.State(host); got != httpx.CircuitClosed {
+		t.Errorf("State() after successes = %v, want %v", got, httpx.CircuitClosed)
+	}
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy.MinRetryDelay = time.Millisecond
+	client.RetryPolicy.MaxRetryDelay = time.Millisecond
+	client.CircuitBreaker = httpx.DefaultCircuitBreaker()
+	client.CircuitBreaker.MinRequests = 2
+
+	resp, err := client.Get(context.Background(), server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected error, got nil")
+	}
+
+	var openErr *httpx.CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("got error %v, want *httpx.CircuitOpenError", err)
+	}
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if openErr.Host != u.Host {
+		t.Errorf("CircuitOpenError.Host = %q, want %q", openErr.Host, u.Host)
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("server received %d requests, want 2", got)
+	}
+}
+
+// closeTracker is a request body that records whether it was closed.
+type closeTracker struct {
+	*strings.Reader
+
+	closed atomic.Bool
+}
+
+func (b *closeTracker) Close() error {
+	b.closed.Store(true)
+
+	return nil
+}
+
+func TestClient_CircuitBreakerClosesBody(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy.MinRetryDelay = time.Millisecond
+	client.RetryPolicy.MaxRetryDelay = time.Millisecond
+	client.CircuitBreaker = httpx.DefaultCircuitBreaker()
+	client.CircuitBreaker.MinRequests = 2
+
+	resp, err := client.Get(context.Background(), server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected error, got nil")
+	}
+
+	body := &closeTracker{Reader: strings.NewReader("payload")}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var openErr *httpx.CircuitOpenError
+
+	resp, err = client.Do(context.Background(), req)
+	if !errors.As(err, &openErr) {
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		t.Fatalf("got error %v, want *httpx.CircuitOpenError", err)
+	}
+
+	if !body.closed.Load() {
+		t.Error("request body was not closed")
+	}
+}
+
+func TestClient_CircuitBreakerNonIdempotent(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.CircuitBreaker = httpx.DefaultCircuitBreaker()
+	client.CircuitBreaker.MinRequests = 2
+
+	var err error
+
+	for i := 0; i < 3; i++ {
+		var resp *http.Response
+
+		resp, err = client.Post(context.Background(), server.URL, "text/plain", strings.NewReader("payload"))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	var openErr *httpx.CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("got error %v, want *httpx.CircuitOpenError", err)
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("server received %d requests, want 2", got)
+	}
+}
+
+func TestClient_CircuitBreakerRetryableStatusCodes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		status   int
+		wantOpen bool
+	}{
+		{
+			name:     "custom code counts as failure",
+			status:   http.StatusTeapot,
+			wantOpen: true,
+		},
+		{
+			name:     "other server error is ignored",
+			status:   http.StatusNotImplemented,
+			wantOpen: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			t.Cleanup(server.Close)
+
+			serverURL, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := httpx.NewClient()
+			client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+			client.RetryPolicy.RetryableStatusCodes = []int{http.StatusTeapot}
+			client.RetryPolicy.MaxRetries = 1
+			client.CircuitBreaker = httpx.DefaultCircuitBreaker()
+			client.CircuitBreaker.MinRequests = 2
+
+			for i := 0; i < 2; i++ {
+				resp, err := client.Post(context.Background(), server.URL, "text/plain", strings.NewReader("payload"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				resp.Body.Close()
+			}
+
+			if got := client.CircuitBreaker.State(serverURL.Host) == httpx.CircuitOpen; got != tt.wantOpen {
+				t.Errorf("got circuit open %v, want %v", got, tt.wantOpen)
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"git.sr.ht/~jamesponddotco/httpx-go/internal/build"
 	"git.sr.ht/~jamesponddotco/pagecache-go"
 	"git.sr.ht/~jamesponddotco/pagecache-go/memorycachex"
+	"git.sr.ht/~jamesponddotco/xstd-go/xcrypto/xuuid"
 	"golang.org/x/time/rate"
 )
 
@@ -34,6 +35,10 @@ type Client struct {
 	// good way to be a good citizen.
 	RateLimiter *rate.Limiter
 
+	// Throttle optionally limits the bandwidth used by request and response
+	// bodies, across all hosts and for each host.
+	Throttle *Throttle
+
 	// RetryPolicy specifies the policy for retrying requests.
 	RetryPolicy *RetryPolicy
 
@@ -41,31 +46,68 @@ type Client struct {
 	UserAgent *UserAgent
 
 	// Transport specifies the mechanism by which individual HTTP requests are
-	// made. If nil, DefaultTransport is used.
+	// made. If nil, DefaultTransport is used. An [*http.Transport] is copied
+	// on first use, so that its connections can be tracked by the client;
+	// changes made to it afterwards have no effect on the client.
+	//
+	// [*http.Transport]: https://godocs.io/net/http#Transport
 	Transport http.RoundTripper
 
 	// CheckRedirect specifies the policy for handling redirects. If
-	// CheckRedirect is nil, the Client uses its default policy, which is to
-	// not follow redirects at all.
+	// CheckRedirect is nil, RedirectPolicy is used instead. If both are nil,
+	// the Client uses its default policy, which is to not follow redirects at
+	// all.
 	CheckRedirect func(req *http.Request, via []*http.Request) error
 
+	// RedirectPolicy specifies a built-in policy for following redirects. It
+	// is ignored if CheckRedirect is set.
+	RedirectPolicy *RedirectPolicy
+
 	// Jar specifies the cookie jar. If nil, cookies are only sent if they are
 	// explicitly set on the Request.
 	Jar http.CookieJar
 
-	// Cache is an optional cache mechanism to store HTTP responses.
+	// Cache is an optional cache mechanism to store HTTP responses. Requests
+	// with a Cache-Control: no-store header bypass it.
 	Cache pagecache.Cache
 
 	// Logger is the logger to use for logging requests when debugging.
 	Logger Logger
 
+	// HedgePolicy is an optional policy for sending additional attempts of
+	// idempotent requests that are slow to respond, returning whichever
+	// response arrives first.
+	HedgePolicy *HedgePolicy
+
+	// RetryBudget is an optional budget shared by all requests made by the
+	// client that caps how many retries can be made relative to the number of
+	// requests. Once the budget is exhausted, requests are no longer retried.
+	RetryBudget *RetryBudget
+
+	// CircuitBreaker is an optional circuit breaker that makes requests to
+	// failing hosts fail fast instead of being sent and retried.
+	CircuitBreaker *CircuitBreaker
+
+	// Metrics is an optional collector for request, retry, rate limiter and
+	// cache metrics.
+	Metrics Metrics
+
 	// Timeout is the timeout for all requests made by the client, overriding
 	// the default value set in the underlying http.Client.
 	Timeout time.Duration
 
+	// IdempotencyKeys specifies whether the client should attach a unique
+	// Idempotency-Key header to requests with non-idempotent methods that don't
+	// have one, making them eligible for retries. The same key is sent on every
+	// attempt of a request.
+	IdempotencyKeys bool
+
 	// Debug specifies whether or not to enable debug logging.
 	Debug bool
 
+	// conns collects connection statistics for ConnStats.
+	conns *connTracker
+
 	// initOnce ensures the client is initialized only once.
 	initOnce sync.Once
 }
@@ -104,6 +146,8 @@ func NewClientWithCache(cache pagecache.Cache) *Client {
 func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
 	c.initClient()
 	c.setUserAgent(req)
+	c.throttleRequest(req)
+	trackUpload(req)
 
 	var (
 		resp *http.Response
@@ -113,14 +157,32 @@ func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, err
 
 	c.debugf("[DEBUG] Starting request %s %s", req.Method, req.URL)
 
-	if c.Cache != nil {
+	cacheable := c.Cache != nil && !isStreaming(req.Context()) && !isNoStore(req)
+
+	if cacheable {
 		key = c.cacheKey(req)
 
 		resp, err = c.Cache.Get(ctx, key)
 		if resp != nil && err == nil {
 			c.debugf("[DEBUG] Cache hit for request: %s %s", req.Method, req.URL)
+			c.metrics().ObserveCacheHit(requestHost(req))
+			trackDownload(req, resp)
+			c.closeRequestBody(req)
+
 			return resp, nil
 		}
+
+		c.metrics().ObserveCacheMiss(requestHost(req))
+	}
+
+	if err = c.setIdempotencyKey(req); err != nil {
+		c.closeRequestBody(req)
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if c.RetryBudget != nil {
+		c.RetryBudget.Deposit()
 	}
 
 	maxRetries := c.maxRetries()
@@ -128,37 +190,61 @@ func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, err
 	for i := 0; i < maxRetries; i++ {
 		c.debugf("[DEBUG] Attempt %d for request: %s %s", i+1, req.Method, req.URL)
 
-		if err = c.applyRateLimiter(i, req); err != nil {
-			return nil, fmt.Errorf("%w", err)
-		}
+		if i > 0 {
+			c.discardResponse(resp)
 
-		resp, err = c.client.Do(req)
-		if err != nil {
-			select {
-			case <-req.Context().Done():
-				return nil, fmt.Errorf("%w", req.Context().Err())
-			default:
-			}
+			if err = rewindBody(req); err != nil {
+				c.closeRequestBody(req)
 
-			if errors.Is(err, context.DeadlineExceeded) {
 				return nil, fmt.Errorf("%w", err)
 			}
+		}
+
+		if err = c.applyRateLimiter(i, req); err != nil {
+			c.closeRequestBody(req)
 
 			return nil, fmt.Errorf("%w", err)
 		}
 
-		if c.RetryPolicy != nil && c.RetryPolicy.ShouldRetry(resp) {
-			if err = c.RetryPolicy.Wait(ctx, resp); err != nil {
-				return nil, fmt.Errorf("%w", err)
-			}
+		if err = c.allowRequest(req); err != nil {
+			c.closeRequestBody(req)
+
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		start := time.Now()
+
+		resp, err = c.attempt(req)
+		if err == nil {
+			c.throttleResponse(req, resp)
+		}
+
+		c.observeRequest(req, resp, start)
+		c.recordRequest(req, resp, err)
 
-			continue
+		if err != nil && req.Context().Err() != nil {
+			return nil, fmt.Errorf("%w", req.Context().Err())
 		}
 
-		break
+		delay, retry := c.retryDelay(ctx, req, resp, err, i, maxRetries)
+		if !retry {
+			break
+		}
+
+		if err = c.RetryPolicy.sleep(ctx, delay); err != nil {
+			c.discardResponse(resp)
+
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		c.metrics().ObserveRetry(requestHost(req), req.Method)
 	}
 
-	if c.Cache != nil {
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if cacheable {
 		policy := c.Cache.Policy()
 
 		if err = c.Cache.Set(ctx, key, resp, policy.TTL(resp)); err != nil {
@@ -166,8 +252,11 @@ func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, err
 		}
 
 		c.debugf("[DEBUG] Cache set for request: %s %s", req.Method, req.URL)
+		c.metrics().ObserveCacheSet(requestHost(req))
 	}
 
+	trackDownload(req, resp)
+
 	return resp, nil
 }
 
@@ -226,15 +315,44 @@ func (c *Client) initClient() {
 			c.client.Transport = c.Transport
 		}
 
-		if c.CheckRedirect != nil {
+		c.conns = newConnTracker()
+
+		tracking := &connTrackingTransport{
+			base:    c.client.Transport,
+			tracker: c.conns,
+		}
+
+		if tracking.base == nil {
+			tracking.base = http.DefaultTransport
+		}
+
+		// The transport may be shared with other clients, so connections are
+		// tracked on a copy owned by this client instead.
+		if transport, ok := tracking.base.(*http.Transport); ok {
+			transport = transport.Clone()
+			c.conns.wrapDial(transport)
+
+			unix := newUnixTransport(transport)
+			c.conns.wrapDial(unix.transport)
+
+			tracking.base = transport
+			tracking.unix = unix
+		}
+
+		c.client.Transport = tracking
+
+		switch {
+		case c.CheckRedirect != nil:
 			c.client.CheckRedirect = c.CheckRedirect
+		case c.RedirectPolicy != nil:
+			c.client.CheckRedirect = c.RedirectPolicy.CheckRedirect
 		}
 
 		if c.Jar != nil {
 			c.client.Jar = c.Jar
 		}
 
-		if c.client.Timeout == 0 && c.Timeout != 0 {
+		if c.Timeout != 0 {
 			c.client.Timeout = c.Timeout
 		}
 
@@ -251,6 +369,24 @@ func (c *Client) setUserAgent(req *http.Request) {
 	}
 }
 
+// setIdempotencyKey sets the Idempotency-Key header on requests with
+// non-idempotent methods if the client is configured to do so and the header
+// is not already set.
+func (c *Client) setIdempotencyKey(req *http.Request) error {
+	if !c.IdempotencyKeys || isIdempotent(req.Method) || req.Header.Get(_headerIdempotencyKey) != "" {
+		return nil
+	}
+
+	key, err := xuuid.New()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	req.Header.Set(_headerIdempotencyKey, key.String())
+
+	return nil
+}
+
 // maxRetries returns the maximum number of retries for a request.
 func (c *Client) maxRetries() int {
 	if c.RetryPolicy != nil {
@@ -260,6 +396,86 @@ func (c *Client) maxRetries() int {
 	return 1
 }
 
+// attempt sends a single attempt of the request, bounded by the retry policy's
+// per-attempt timeout, if any. Streaming requests are not bounded, since the
+// timeout would also cut off reading their responses.
+func (c *Client) attempt(req *http.Request) (*http.Response, error) {
+	if c.RetryPolicy == nil || c.RetryPolicy.PerAttemptTimeout <= 0 || isStreaming(req.Context()) {
+		return c.send(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), c.RetryPolicy.PerAttemptTimeout)
+
+	resp, err := c.send(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+
+		return nil, err
+	}
+
+	resp.Body = &cancelBody{
+		ReadCloser: resp.Body,
+		cancel:     cancel,
+	}
+
+	return resp, nil
+}
+
+// retryDelay reports whether the attempt that returned the given response or
+// error should be retried, and how long to wait before doing so.
+//
+// A retry is skipped if the remaining time before the context's deadline is
+// shorter than the delay, since the next attempt could never complete.
+func (c *Client) retryDelay(
+	ctx context.Context,
+	req *http.Request,
+	resp *http.Response,
+	err error,
+	attempt, maxRetries int,
+) (time.Duration, bool) {
+	if c.RetryPolicy == nil || !c.RetryPolicy.IsRetryable(req) {
+		return 0, false
+	}
+
+	if err != nil && !c.RetryPolicy.ShouldRetryError(err) {
+		return 0, false
+	}
+
+	if err == nil && !c.RetryPolicy.ShouldRetry(resp) {
+		return 0, false
+	}
+
+	delay := c.RetryPolicy.RetryAfter(resp)
+
+	if exceedsDeadline(ctx, delay) || exceedsDeadline(req.Context(), delay) {
+		c.debugf("[DEBUG] Deadline too close to retry request: %s %s", req.Method, req.URL)
+
+		return 0, false
+	}
+
+	if !c.retryAllowed(attempt, maxRetries, req) {
+		return 0, false
+	}
+
+	return delay, true
+}
+
+// retryAllowed reports whether another attempt may follow the given attempt,
+// taking the retry budget into account.
+func (c *Client) retryAllowed(attempt, maxRetries int, req *http.Request) bool {
+	if attempt >= maxRetries-1 || !canRewindBody(req) {
+		return false
+	}
+
+	if c.RetryBudget != nil && !c.RetryBudget.Withdraw() {
+		c.debugf("[DEBUG] Retry budget exhausted for request: %s %s", req.Method, req.URL)
+
+		return false
+	}
+
+	return true
+}
+
 // cacheKey returns the cache key for a request.
 func (*Client) cacheKey(req *http.Request) string {
 	return pagecache.Key(build.Name, req)
@@ -270,14 +486,218 @@ func (c *Client) applyRateLimiter(count int, req *http.Request) error {
 	if count > 0 && c.RateLimiter != nil {
 		c.debugf("[DEBUG] Applying rate limiter for request: %s %s", req.Method, req.URL)
 
+		start := time.Now()
+
 		if err := c.RateLimiter.Wait(req.Context()); err != nil {
 			return fmt.Errorf("%w", err)
 		}
+
+		c.metrics().ObserveRateLimiterWait(requestHost(req), time.Since(start))
+	}
+
+	return nil
+}
+
+// allowRequest checks the circuit breaker, if any, for the request's host.
+func (c *Client) allowRequest(req *http.Request) error {
+	if c.CircuitBreaker == nil {
+		return nil
+	}
+
+	if err := c.CircuitBreaker.Allow(requestHost(req)); err != nil {
+		c.debugf("[DEBUG] Circuit breaker open for request: %s %s", req.Method, req.URL)
+
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// recordRequest reports the outcome of a request attempt to the circuit
+// breaker, if any.
+func (c *Client) recordRequest(req *http.Request, resp *http.Response, err error) {
+	if c.CircuitBreaker == nil {
+		return
+	}
+
+	if errors.Is(err, context.Canceled) {
+		c.CircuitBreaker.Release(requestHost(req))
+
+		return
+	}
+
+	c.CircuitBreaker.Done(requestHost(req), c.isFailure(resp, err))
+}
+
+// isFailure reports whether a request attempt failed because of the host,
+// regardless of the request's method. Errors and status codes the RetryPolicy
+// considers retryable count as failures; without a RetryPolicy, every error,
+// server error and response asking the client to slow down does.
+func (c *Client) isFailure(resp *http.Response, err error) bool {
+	if c.RetryPolicy != nil {
+		if err != nil {
+			return c.RetryPolicy.ShouldRetryError(err)
+		}
+
+		return c.RetryPolicy.ShouldRetry(resp)
+	}
+
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// discardResponse drains and closes the body of a response that will not be
+// returned to the caller.
+func (c *Client) discardResponse(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+
+	if err := DrainResponseBody(resp); err != nil {
+		c.debugf("[DEBUG] Failed to discard response: %v", err)
+	}
+}
+
+// closeRequestBody closes the body of a request that will not be sent, as
+// http.Client.Do does when it fails before sending a request.
+func (c *Client) closeRequestBody(req *http.Request) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	if err := req.Body.Close(); err != nil {
+		c.debugf("[DEBUG] Failed to close request body: %v", err)
+	}
+}
+
+// metrics returns the metrics collector for the client, or a no-op collector
+// if none has been set.
+func (c *Client) metrics() Metrics {
+	if c.Metrics != nil {
+		return c.Metrics
+	}
+
+	return nopMetrics{}
+}
+
+// observeRequest records a request attempt that started at the given time.
+func (c *Client) observeRequest(req *http.Request, resp *http.Response, start time.Time) {
+	var statusCode int
+
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+
+	c.metrics().ObserveRequest(requestHost(req), req.Method, statusCode, time.Since(start))
+}
+
+// streamingKey is the context key marking requests whose responses are
+// streamed for an unbounded amount of time.
+type streamingKey struct{}
+
+// WithStreaming returns a copy of ctx marking requests made with it as
+// streaming. Streaming requests bypass the cache, the client's Timeout and the
+// RetryPolicy's PerAttemptTimeout, so that reading their responses is only
+// limited by the context. Use it to read large responses, such as exports
+// decoded with ReadJSONArray or ReadNDJSON, that take longer than the client's
+// timeout to download.
+func WithStreaming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamingKey{}, true)
+}
+
+// isStreaming reports whether ctx marks requests as streaming.
+func isStreaming(ctx context.Context) bool {
+	streaming, ok := ctx.Value(streamingKey{}).(bool)
+
+	return ok && streaming
+}
+
+// isNoStore reports whether the request asks not to be served from or stored
+// in a cache.
+func isNoStore(req *http.Request) bool {
+	for _, value := range req.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// httpClient returns the http.Client used to send the request. Streaming
+// requests use a copy of the client without a timeout.
+func (c *Client) httpClient(req *http.Request) *http.Client {
+	if !isStreaming(req.Context()) || c.client.Timeout == 0 {
+		return c.client
 	}
 
+	client := *c.client
+	client.Timeout = 0
+
+	return &client
+}
+
+// exceedsDeadline reports whether the context's deadline, if any, will pass
+// before the given delay.
+func exceedsDeadline(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+
+	return ok && time.Until(deadline) < delay
+}
+
+// canRewindBody reports whether the request's body, if any, can be sent again.
+func canRewindBody(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindBody replaces the request's body with a fresh copy so that the request
+// can be sent again.
+func rewindBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	req.Body = body
+
 	return nil
 }
 
+// isIdempotent reports whether the given HTTP method is idempotent, as defined
+// in RFC 9110, section 9.2.2.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// defaultPort returns the port of the URL, falling back to the default port
+// for its scheme.
+func defaultPort(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return "443"
+	case "http":
+		return "80"
+	case "socks5", "socks5h":
+		return "1080"
+	default:
+		return ""
+	}
+}
+
 // debugf is a convenience method for logging debug messages.
 func (c *Client) debugf(format string, args ...any) {
 	if c.Debug && c.Logger != nil {
@@ -0,0 +1,384 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// ErrCannotDecodeBody is returned when a response body cannot be decoded.
+	ErrCannotDecodeBody xerrors.Error = "cannot decode response body"
+
+	// ErrCannotEncodeBody is returned when a request body cannot be encoded.
+	ErrCannotEncodeBody xerrors.Error = "cannot encode request body"
+
+	// ErrUnsupportedMediaType is returned when no codec is registered for the
+	// media type of a request body.
+	ErrUnsupportedMediaType xerrors.Error = "unsupported media type"
+
+	// ErrUnsupportedValue is returned when a codec cannot encode or decode
+	// values of a given type.
+	ErrUnsupportedValue xerrors.Error = "unsupported value type"
+)
+
+const (
+	// _mediaTypeJSON is the Content-Type of JSON bodies.
+	_mediaTypeJSON string = "application/json"
+
+	// _mediaTypeTextXML is the legacy Content-Type of XML bodies.
+	_mediaTypeTextXML string = "text/xml"
+)
+
+// Codec encodes and decodes bodies of one or more media types.
+type Codec interface {
+	// MediaTypes returns the media types handled by the codec, without
+	// parameters.
+	MediaTypes() []string
+
+	// Encode writes the encoding of v to w.
+	Encode(w io.Writer, v any) error
+
+	// Decode decodes the body read from r into v. Textual bodies are converted
+	// to UTF-8 beforehand if their Content-Type has a charset parameter.
+	Decode(r io.Reader, v any) error
+}
+
+// Codecs is a registry of codecs keyed by media type, used to pick how to
+// decode a response from its Content-Type and how to encode a request body.
+//
+// Media types with a structured syntax suffix, such as
+// application/problem+json, fall back to the codec of the suffix, such as
+// application/json, if no codec is registered for them.
+type Codecs struct {
+	// codecs holds the registered codecs keyed by media type.
+	codecs map[string]Codec
+
+	// mediaTypes lists the registered media types in registration order.
+	mediaTypes []string
+
+	// mu protects codecs and mediaTypes.
+	mu sync.RWMutex
+}
+
+// NewCodecs returns a new Codecs with the given codecs registered.
+func NewCodecs(codecs ...Codec) *Codecs {
+	cs := &Codecs{
+		codecs: make(map[string]Codec),
+	}
+
+	for _, codec := range codecs {
+		cs.Register(codec)
+	}
+
+	return cs
+}
+
+// DefaultCodecs returns a new Codecs with the JSON, XML and form codecs
+// registered, in that order of preference.
+func DefaultCodecs() *Codecs {
+	return NewCodecs(JSONCodec{}, XMLCodec{}, FormCodec{})
+}
+
+// Register registers the codec for its media types, replacing any codec
+// previously registered for them.
+func (cs *Codecs) Register(codec Codec) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, mediaType := range codec.MediaTypes() {
+		mediaType = strings.ToLower(mediaType)
+
+		if _, ok := cs.codecs[mediaType]; !ok {
+			cs.mediaTypes = append(cs.mediaTypes, mediaType)
+		}
+
+		cs.codecs[mediaType] = codec
+	}
+}
+
+// Lookup returns the codec registered for the given media type, which must not
+// have parameters, falling back to the codec of its structured syntax suffix.
+func (cs *Codecs) Lookup(mediaType string) (Codec, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	mediaType = strings.ToLower(mediaType)
+
+	if codec, ok := cs.codecs[mediaType]; ok {
+		return codec, true
+	}
+
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		codec, ok := cs.codecs["application/"+mediaType[i+1:]]
+
+		return codec, ok
+	}
+
+	return nil, false
+}
+
+// MediaTypes returns the registered media types in registration order.
+func (cs *Codecs) MediaTypes() []string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	mediaTypes := make([]string, len(cs.mediaTypes))
+	copy(mediaTypes, cs.mediaTypes)
+
+	return mediaTypes
+}
+
+// Accept returns a value for the Accept header listing the registered media
+// types in registration order.
+func (cs *Codecs) Accept() string {
+	return strings.Join(cs.MediaTypes(), ", ")
+}
+
+// ReadBody decodes the body of the response into v with the codec registered
+// for its Content-Type, converting textual bodies to UTF-8 according to the
+// charset parameter first. The body is not closed.
+//
+// If the response has no Content-Type or no codec is registered for it, such
+// as for an HTML error page returned by a proxy, ReadBody returns an
+// *UnexpectedContentTypeError without reading the body.
+func (cs *Codecs) ReadBody(resp *http.Response, v any) error {
+	contentType := resp.Header.Get("Content-Type")
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return cs.unexpectedContentType(resp, contentType)
+	}
+
+	codec, ok := cs.Lookup(mediaType)
+	if !ok {
+		return cs.unexpectedContentType(resp, contentType)
+	}
+
+	var body io.Reader = resp.Body
+
+	if label, ok := params["charset"]; ok {
+		var converted io.Reader
+
+		if converted, err = charset.NewReaderLabel(label, resp.Body); err != nil {
+			return fmt.Errorf("%w: %w", ErrCannotDecodeBody, err)
+		}
+
+		body = &utf8Reader{Reader: converted}
+	}
+
+	if err = codec.Decode(body, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotDecodeBody, err)
+	}
+
+	return nil
+}
+
+// NewRequest returns a new request whose body is v encoded with the codec
+// registered for mediaType, which is also set as its Content-Type, and whose
+// Accept header lists the registered media types. If v is nil, the request has
+// no body and only the Accept header is set.
+func (cs *Codecs) NewRequest(ctx context.Context, method, uri, mediaType string, v any) (*http.Request, error) {
+	var body io.Reader = http.NoBody
+
+	if v != nil {
+		parsed, _, err := mime.ParseMediaType(mediaType)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnsupportedMediaType, err)
+		}
+
+		codec, ok := cs.Lookup(parsed)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+		}
+
+		buf := &bytes.Buffer{}
+
+		if err = codec.Encode(buf, v); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCannotEncodeBody, err)
+		}
+
+		body = buf
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if v != nil {
+		req.Header.Set("Content-Type", mediaType)
+	}
+
+	if accept := cs.Accept(); accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	return req, nil
+}
+
+// unexpectedContentType returns an *UnexpectedContentTypeError for the
+// response.
+func (cs *Codecs) unexpectedContentType(resp *http.Response, contentType string) error {
+	err := &UnexpectedContentTypeError{
+		ContentType: contentType,
+		Expected:    cs.MediaTypes(),
+		StatusCode:  resp.StatusCode,
+	}
+
+	if resp.Request != nil {
+		err.URL = resp.Request.URL
+	}
+
+	return err
+}
+
+// ReadBody decodes the body of the response into v with the default codecs,
+// picking the decoder by Content-Type. See Codecs.ReadBody for details.
+func ReadBody(resp *http.Response, v any) error {
+	return DefaultCodecs().ReadBody(resp, v)
+}
+
+// utf8Reader marks a body already converted to UTF-8 according to the charset
+// parameter of its Content-Type, which takes precedence over any encoding
+// declared in the body itself.
+type utf8Reader struct {
+	io.Reader
+}
+
+// JSONCodec is a Codec for JSON bodies.
+type JSONCodec struct{}
+
+// Compile-time check to ensure JSONCodec implements the Codec interface.
+var _ Codec = JSONCodec{}
+
+// MediaTypes returns the JSON media type.
+func (JSONCodec) MediaTypes() []string {
+	return []string{_mediaTypeJSON}
+}
+
+// Encode writes v as JSON.
+func (JSONCodec) Encode(w io.Writer, v any) error {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Decode decodes a JSON value into v.
+func (JSONCodec) Decode(r io.Reader, v any) error {
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// XMLCodec is a Codec for XML bodies. Encodings declared in the body are
+// honored unless the Content-Type has a charset parameter.
+type XMLCodec struct{}
+
+// Compile-time check to ensure XMLCodec implements the Codec interface.
+var _ Codec = XMLCodec{}
+
+// MediaTypes returns the XML media types.
+func (XMLCodec) MediaTypes() []string {
+	return []string{_mediaTypeXML, _mediaTypeTextXML}
+}
+
+// Encode writes v as XML.
+func (XMLCodec) Encode(w io.Writer, v any) error {
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Decode decodes an XML document into v.
+func (XMLCodec) Decode(r io.Reader, v any) error {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	if _, ok := r.(*utf8Reader); ok {
+		decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+			return input, nil
+		}
+	}
+
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// FormCodec is a Codec for URL-encoded form bodies. It encodes and decodes
+// url.Values and map[string][]string values.
+type FormCodec struct{}
+
+// Compile-time check to ensure FormCodec implements the Codec interface.
+var _ Codec = FormCodec{}
+
+// MediaTypes returns the URL-encoded form media type.
+func (FormCodec) MediaTypes() []string {
+	return []string{_mediaTypeFormURLEncoded}
+}
+
+// Encode writes v, which must be a url.Values or a map[string][]string, as a
+// URL-encoded form.
+func (FormCodec) Encode(w io.Writer, v any) error {
+	var values url.Values
+
+	switch v := v.(type) {
+	case url.Values:
+		values = v
+	case map[string][]string:
+		values = v
+	default:
+		return fmt.Errorf("%w: cannot encode %T as a form", ErrUnsupportedValue, v)
+	}
+
+	if _, err := io.WriteString(w, values.Encode()); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Decode decodes a URL-encoded form into v, which must be a *url.Values or a
+// *map[string][]string.
+func (FormCodec) Decode(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	switch v := v.(type) {
+	case *url.Values:
+		*v = values
+	case *map[string][]string:
+		*v = values
+	default:
+		return fmt.Errorf("%w: cannot decode a form into %T", ErrUnsupportedValue, v)
+	}
+
+	return nil
+}
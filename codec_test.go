@@ -0,0 +1,180 @@
+package httpx_test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+)
+
+type codecItem struct {
+	XMLName xml.Name `json:"-" xml:"item"`
+	Name    string   `json:"name" xml:"name"`
+}
+
+func TestReadBody(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        codecItem
+		wantErr     error
+		wantType    bool
+	}{
+		{
+			name:        "JSON",
+			contentType: "application/json",
+			body:        `{"name":"café"}`,
+			want:        codecItem{Name: "café"},
+		},
+		{
+			name:        "structured syntax suffix",
+			contentType: "application/problem+json; charset=utf-8",
+			body:        `{"name":"café"}`,
+			want:        codecItem{Name: "café"},
+		},
+		{
+			name:        "XML with charset parameter",
+			contentType: "application/xml; charset=ISO-8859-1",
+			body:        "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><item><name>caf\xe9</name></item>",
+			want:        codecItem{XMLName: xml.Name{Local: "item"}, Name: "café"},
+		},
+		{
+			name:        "XML with declared encoding",
+			contentType: "text/xml",
+			body:        "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><item><name>caf\xe9</name></item>",
+			want:        codecItem{XMLName: xml.Name{Local: "item"}, Name: "café"},
+		},
+		{
+			name:        "HTML error page",
+			contentType: "text/html; charset=utf-8",
+			body:        "<html><body>502 Bad Gateway</body></html>",
+			wantType:    true,
+		},
+		{
+			name:     "missing Content-Type",
+			body:     `{"name":"café"}`,
+			wantType: true,
+		},
+		{
+			name:        "invalid body",
+			contentType: "application/json",
+			body:        `{"name":`,
+			wantErr:     httpx.ErrCannotDecodeBody,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var got codecItem
+
+			err := httpx.ReadBody(resp, &got)
+
+			if tt.wantType {
+				var typeErr *httpx.UnexpectedContentTypeError
+
+				if !errors.As(err, &typeErr) {
+					t.Fatalf("ReadBody() error = %v, want *UnexpectedContentTypeError", err)
+				}
+
+				if typeErr.ContentType != tt.contentType {
+					t.Errorf("ContentType = %q, want %q", typeErr.ContentType, tt.contentType)
+				}
+
+				return
+			}
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ReadBody() error = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ReadBody() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadBody() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodecs_NewRequest(t *testing.T) {
+	t.Parallel()
+
+	codecs := httpx.DefaultCodecs()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Accept"), codecs.Accept(); got != want {
+			t.Errorf("Accept = %q, want %q", got, want)
+		}
+
+		var values url.Values
+
+		if err := codecs.ReadBody(&http.Response{Header: r.Header, Body: r.Body}, &values); err != nil {
+			t.Errorf("ReadBody() error = %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if _, err := io.WriteString(w, `{"name":"`+values.Get("name")+`"}`); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	client := httpx.NewClientWithCache(nil)
+
+	req, err := codecs.NewRequest(context.Background(), http.MethodPost, server.URL, "application/x-www-form-urlencoded", url.Values{"name": {"widget"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got codecItem
+
+	if err = codecs.ReadBody(resp, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Name != "widget" {
+		t.Errorf("Name = %q, want %q", got.Name, "widget")
+	}
+
+	if _, err = codecs.NewRequest(context.Background(), http.MethodPost, server.URL, "application/yaml", got); !errors.Is(err, httpx.ErrUnsupportedMediaType) {
+		t.Errorf("NewRequest() error = %v, want %v", err, httpx.ErrUnsupportedMediaType)
+	}
+}
@@ -0,0 +1,386 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+)
+
+// ConnStats holds connection pool statistics for a single host.
+type ConnStats struct {
+	// Active is the number of open connections currently serving at least one
+	// request.
+	Active int
+
+	// Idle is the number of open connections waiting in the pool for a
+	// request.
+	Idle int
+
+	// Dialed is the number of requests that needed a newly dialed connection.
+	Dialed uint64
+
+	// Reused is the number of requests that reused an existing connection.
+	Reused uint64
+}
+
+// ReuseRatio returns the ratio of requests that reused an existing connection,
+// between 0 and 1.
+func (s ConnStats) ReuseRatio() float64 {
+	total := s.Dialed + s.Reused
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.Reused) / float64(total)
+}
+
+// ConnStats returns connection statistics for every host the client sent
+// requests to, keyed by host. Requests to http+unix URLs are keyed by the path
+// of the socket.
+//
+// Active and Idle counts are only exact when the client's transport is an
+// [*http.Transport], whose copy owned by the client has its DialContext
+// wrapped to learn when connections close. With other transports, Active
+// counts requests in flight and Idle is always zero.
+//
+// [*http.Transport]: https://godocs.io/net/http#Transport
+func (c *Client) ConnStats() map[string]ConnStats {
+	c.initClient()
+
+	return c.conns.snapshot()
+}
+
+// CloseIdleConnections closes any connections in the transport's pool that are
+// not currently in use. It does not interrupt connections serving requests.
+func (c *Client) CloseIdleConnections() {
+	c.initClient()
+
+	c.client.CloseIdleConnections()
+}
+
+// connTracker collects connection statistics by host.
+type connTracker struct {
+	// hosts holds the statistics for each host.
+	hosts map[string]*hostConns
+
+	// mu protects hosts and the state of every trackedConn.
+	mu sync.Mutex
+}
+
+// hostConns holds the connection counters for a single host.
+type hostConns struct {
+	// open is the number of tracked connections that are open.
+	open int
+
+	// active is the number of connections serving at least one request.
+	active int
+
+	// dialed is the number of requests that used a new connection.
+	dialed uint64
+
+	// reused is the number of requests that reused a connection.
+	reused uint64
+}
+
+// newConnTracker returns a new connTracker.
+func newConnTracker() *connTracker {
+	return &connTracker{
+		hosts: make(map[string]*hostConns),
+	}
+}
+
+// wrapDial wraps the transport's DialContext so that connections dialed by the
+// transport can be tracked until they close.
+func (t *connTracker) wrapDial(transport *http.Transport) {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		return &trackedConn{Conn: conn, tracker: t}, nil
+	}
+}
+
+// acquire records that a request to the given host got a connection. The
+// connection is nil if it was not dialed through wrapDial.
+func (t *connTracker) acquire(host string, conn *trackedConn, reused bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.host(host)
+
+	if reused {
+		h.reused++
+	} else {
+		h.dialed++
+	}
+
+	if conn == nil {
+		h.active++
+
+		return
+	}
+
+	if conn.closed {
+		return
+	}
+
+	if conn.host == "" {
+		conn.host = host
+		h.open++
+	}
+
+	if conn.inUse == 0 {
+		h.active++
+	}
+
+	conn.inUse++
+}
+
+// release records that a request to the given host is done with its
+// connection.
+func (t *connTracker) release(host string, conn *trackedConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.host(host)
+
+	if conn == nil {
+		h.active--
+
+		return
+	}
+
+	if conn.closed || conn.inUse == 0 {
+		return
+	}
+
+	conn.inUse--
+
+	if conn.inUse == 0 {
+		h.active--
+	}
+}
+
+// closed records that a tracked connection closed.
+func (t *connTracker) closed(conn *trackedConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if conn.closed {
+		return
+	}
+
+	conn.closed = true
+
+	if conn.host == "" {
+		return
+	}
+
+	h := t.host(conn.host)
+	h.open--
+
+	if conn.inUse > 0 {
+		h.active--
+	}
+}
+
+// snapshot returns a copy of the statistics for every host.
+func (t *connTracker) snapshot() map[string]ConnStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make(map[string]ConnStats, len(t.hosts))
+
+	for host, h := range t.hosts {
+		idle := h.open - h.active
+		if idle < 0 {
+			idle = 0
+		}
+
+		stats[host] = ConnStats{
+			Active: h.active,
+			Idle:   idle,
+			Dialed: h.dialed,
+			Reused: h.reused,
+		}
+	}
+
+	return stats
+}
+
+// host returns the counters for the given host, creating them if needed. The
+// caller must hold t.mu.
+func (t *connTracker) host(host string) *hostConns {
+	h, ok := t.hosts[host]
+	if !ok {
+		h = &hostConns{}
+		t.hosts[host] = h
+	}
+
+	return h
+}
+
+// trackedConn is a net.Conn that reports to a connTracker when it closes.
+type trackedConn struct {
+	net.Conn
+
+	// tracker is the tracker the connection reports to.
+	tracker *connTracker
+
+	// host is the host of the first request that used the connection.
+	host string
+
+	// inUse is the number of requests currently using the connection.
+	inUse int
+
+	// closed specifies whether the connection was closed.
+	closed bool
+}
+
+// Close closes the underlying connection and reports it to the tracker.
+func (c *trackedConn) Close() error {
+	c.tracker.closed(c)
+
+	if err := c.Conn.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// connTrackingTransport is an http.RoundTripper that reports the connections
+// used by each request to a connTracker through httptrace.
+type connTrackingTransport struct {
+	// base is the transport used to send requests.
+	base http.RoundTripper
+
+	// unix is the transport used to send http+unix requests, if base is an
+	// [*http.Transport].
+	//
+	// [*http.Transport]: https://godocs.io/net/http#Transport
+	unix *unixTransport
+
+	// tracker is the tracker connections are reported to.
+	tracker *connTracker
+}
+
+// RoundTrip implements the http.RoundTripper interface. The connection used by
+// the request is released once the response body is closed.
+func (t *connTrackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		host    = requestHost(req)
+		conn    *trackedConn
+		got     bool
+		release sync.Once
+	)
+
+	base := t.base
+	if t.unix != nil && req.URL.Scheme == SchemeHTTPUnix {
+		base = t.unix
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			// The transport may retry a request on another connection.
+			if got {
+				t.tracker.release(host, conn)
+			}
+
+			conn, got = unwrapTrackedConn(info.Conn), true
+
+			t.tracker.acquire(host, conn, info.Reused)
+		},
+	}
+
+	done := func() {
+		release.Do(func() {
+			if got {
+				t.tracker.release(host, conn)
+			}
+		})
+	}
+
+	resp, err := base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		done()
+
+		return nil, err //nolint:wrapcheck // http.Client inspects the error to report timeouts.
+	}
+
+	resp.Request = req
+
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		done()
+
+		return resp, nil
+	}
+
+	resp.Body = &releaseBody{
+		ReadCloser: resp.Body,
+		release:    done,
+	}
+
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the underlying transports.
+func (t *connTrackingTransport) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+
+	if base, ok := t.base.(closeIdler); ok {
+		base.CloseIdleConnections()
+	}
+
+	if t.unix != nil {
+		t.unix.transport.CloseIdleConnections()
+	}
+}
+
+// releaseBody is an io.ReadCloser that calls release once the body is
+// closed.
+type releaseBody struct {
+	io.ReadCloser
+
+	// release is called when the body is closed.
+	release func()
+}
+
+// Close closes the underlying body and calls release.
+func (b *releaseBody) Close() error {
+	defer b.release()
+
+	if err := b.ReadCloser.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// unwrapTrackedConn returns the trackedConn underlying the given connection,
+// or nil if it was not dialed through connTracker.wrapDial.
+func unwrapTrackedConn(conn net.Conn) *trackedConn {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	tracked, ok := conn.(*trackedConn)
+	if !ok {
+		return nil
+	}
+
+	return tracked
+}
@@ -0,0 +1,171 @@
+package httpx_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+func TestClient_ConnStats(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(context.Background(), server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	want := httpx.ConnStats{Idle: 1, Dialed: 1, Reused: 2}
+
+	got := client.ConnStats()[serverURL.Host]
+	if got != want {
+		t.Errorf("after sequential requests: got %+v, want %+v", got, want)
+	}
+
+	if ratio := got.ReuseRatio(); ratio < 0.66 || ratio > 0.67 {
+		t.Errorf("got reuse ratio %f, want 2/3", ratio)
+	}
+
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want = httpx.ConnStats{Active: 1, Dialed: 1, Reused: 3}
+
+	if got = client.ConnStats()[serverURL.Host]; got != want {
+		t.Errorf("with open response: got %+v, want %+v", got, want)
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	client.CloseIdleConnections()
+
+	want = httpx.ConnStats{Dialed: 1, Reused: 3}
+
+	if got = client.ConnStats()[serverURL.Host]; got != want {
+		t.Errorf("after closing idle connections: got %+v, want %+v", got, want)
+	}
+}
+
+func TestClient_ConnStatsSharedTransport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		transport = httpx.DefaultTransport()
+		clients   = make([]*httpx.Client, 4)
+		wg        sync.WaitGroup
+	)
+
+	for i := range clients {
+		clients[i] = httpx.NewClient()
+		clients[i].Transport = transport
+		clients[i].RateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+		wg.Add(1)
+
+		go func(client *httpx.Client) {
+			defer wg.Done()
+
+			for j := 0; j < 3; j++ {
+				resp, err := client.Get(context.Background(), server.URL)
+				if err != nil {
+					t.Error(err)
+
+					return
+				}
+
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		}(clients[i])
+	}
+
+	wg.Wait()
+
+	want := httpx.ConnStats{Idle: 1, Dialed: 1, Reused: 2}
+
+	for i, client := range clients {
+		if got := client.ConnStats()[serverURL.Host]; got != want {
+			t.Errorf("client %d: got %+v, want %+v", i, got, want)
+		}
+
+		client.CloseIdleConnections()
+	}
+}
+
+func TestClient_ConnStatsUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	socket := newUnixServer(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	transport, err := httpx.NewTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := httpx.NewClient()
+	client.Transport = transport
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(context.Background(), httpx.SchemeHTTPUnix+"://"+socket+":/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	want := httpx.ConnStats{Idle: 1, Dialed: 1, Reused: 1}
+
+	if got := client.ConnStats()[socket]; got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	client.CloseIdleConnections()
+
+	want = httpx.ConnStats{Dialed: 1, Reused: 1}
+
+	if got := client.ConnStats()[socket]; got != want {
+		t.Errorf("after closing idle connections: got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,6 @@
+// Package cookiejarx provides a persistent, [RFC 6265] compliant
+// implementation of [http.CookieJar] that can be saved to and loaded from disk.
+//
+// [RFC 6265]: https://tools.ietf.org/html/rfc6265
+// [http.CookieJar]: https://godocs.io/net/http#CookieJar
+package cookiejarx
@@ -0,0 +1,268 @@
+package cookiejarx
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrCannotLoad is returned when the jar cannot be loaded from disk.
+	ErrCannotLoad xerrors.Error = "cannot load cookie jar"
+
+	// ErrCannotSave is returned when the jar cannot be saved to disk.
+	ErrCannotSave xerrors.Error = "cannot save cookie jar"
+
+	// ErrInvalidNetscapeLine is returned when a line in a Netscape cookies file
+	// cannot be parsed.
+	ErrInvalidNetscapeLine xerrors.Error = "invalid Netscape cookie line"
+)
+
+const (
+	// _netscapeHTTPOnlyPrefix is the prefix used by curl and browsers to mark
+	// HttpOnly cookies in Netscape cookies files.
+	_netscapeHTTPOnlyPrefix string = "#HttpOnly_"
+
+	// _netscapeHeader is the header written at the top of Netscape cookies
+	// files.
+	_netscapeHeader string = "# Netscape HTTP Cookie File\n"
+
+	// _netscapeFields is the number of tab-separated fields in a Netscape
+	// cookie line.
+	_netscapeFields int = 7
+)
+
+// Format is the file format used to persist a Jar.
+type Format int
+
+const (
+	// FormatJSON stores cookies as a JSON array.
+	FormatJSON Format = iota
+
+	// FormatNetscape stores cookies in the Netscape cookies.txt format used by
+	// curl, wget and most browser extensions.
+	FormatNetscape
+)
+
+// Load replaces the cookies in the jar with the ones saved to its file,
+// skipping any that have expired or whose domain would be rejected by
+// SetCookies, such as domain cookies for a public suffix. It returns an error
+// wrapping os.ErrNotExist if the file does not exist.
+func (j *Jar) Load() error {
+	if j.filename == "" {
+		return nil
+	}
+
+	file, err := os.Open(j.filename)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotLoad, err)
+	}
+	defer file.Close()
+
+	var entries []entry
+
+	switch j.format {
+	case FormatNetscape:
+		entries, err = readNetscape(file)
+	case FormatJSON:
+		err = json.NewDecoder(file).Decode(&entries)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotLoad, err)
+	}
+
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = make(map[string]map[string]entry)
+
+	for i := range entries {
+		j.add(&entries[i], now)
+	}
+
+	return nil
+}
+
+// Save writes the persistent, unexpired cookies in the jar to its file. The
+// file is replaced atomically and is only readable by its owner.
+func (j *Jar) Save() error {
+	if j.filename == "" {
+		return nil
+	}
+
+	entries := j.persistentEntries()
+
+	tmp, err := os.CreateTemp(filepath.Dir(j.filename), filepath.Base(j.filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotSave, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	switch j.format {
+	case FormatNetscape:
+		err = writeNetscape(tmp, entries)
+	case FormatJSON:
+		encoder := json.NewEncoder(tmp)
+		encoder.SetIndent("", "  ")
+
+		err = encoder.Encode(entries)
+	}
+
+	if err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("%w: %w", ErrCannotSave, err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotSave, err)
+	}
+
+	if err = os.Rename(tmp.Name(), j.filename); err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotSave, err)
+	}
+
+	return nil
+}
+
+// persistentEntries returns a sorted copy of the persistent, unexpired
+// entries in the jar.
+func (j *Jar) persistentEntries() []entry {
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := make([]entry, 0, len(j.entries))
+
+	for _, submap := range j.entries {
+		for _, e := range submap {
+			if e.Persistent && e.Expires.After(now) {
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, k int) bool {
+		return entries[i].id() < entries[k].id()
+	})
+
+	return entries
+}
+
+// readNetscape parses cookies in the Netscape cookies.txt format.
+func readNetscape(r io.Reader) ([]entry, error) {
+	var (
+		entries []entry
+		scanner = bufio.NewScanner(r)
+		line    int
+	)
+
+	for scanner.Scan() {
+		line++
+
+		// Only the line ending is trimmed, since the value of a cookie with an
+		// empty value is followed by a trailing tab.
+		var (
+			text     = strings.TrimRight(scanner.Text(), "\r\n")
+			httpOnly bool
+		)
+
+		if strings.HasPrefix(text, _netscapeHTTPOnlyPrefix) {
+			text = strings.TrimPrefix(text, _netscapeHTTPOnlyPrefix)
+			httpOnly = true
+		}
+
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) != _netscapeFields {
+			return nil, fmt.Errorf("%w: line %d", ErrInvalidNetscapeLine, line)
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidNetscapeLine, line, err)
+		}
+
+		e := entry{
+			Domain:   strings.ToLower(strings.TrimPrefix(fields[0], ".")),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HTTPOnly: httpOnly,
+		}
+
+		if expires > 0 {
+			e.Expires = time.Unix(expires, 0)
+			e.Persistent = true
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return entries, nil
+}
+
+// writeNetscape writes cookies in the Netscape cookies.txt format.
+func writeNetscape(w io.Writer, entries []entry) error {
+	buf := bufio.NewWriter(w)
+
+	if _, err := buf.WriteString(_netscapeHeader); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	for i := range entries {
+		var (
+			e                 = &entries[i]
+			domain            = e.Domain
+			includeSubdomains = "FALSE"
+			secure            = "FALSE"
+		)
+
+		if !e.HostOnly {
+			domain = "." + domain
+			includeSubdomains = "TRUE"
+		}
+
+		if e.HTTPOnly {
+			domain = _netscapeHTTPOnlyPrefix + domain
+		}
+
+		if e.Secure {
+			secure = "TRUE"
+		}
+
+		_, err := fmt.Fprintf(buf, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, includeSubdomains, e.Path, secure, e.Expires.Unix(), e.Name, e.Value)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,525 @@
+package cookiejarx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	// ErrIllegalDomain is returned when a cookie's domain attribute does not
+	// domain-match the host that set it, or is a public suffix.
+	ErrIllegalDomain xerrors.Error = "illegal cookie domain attribute"
+
+	// ErrMalformedDomain is returned when a cookie's domain attribute is
+	// malformed.
+	ErrMalformedDomain xerrors.Error = "malformed cookie domain attribute"
+
+	// ErrNoHostname is returned when a cookie with a domain attribute is set
+	// by a host that is an IP address.
+	ErrNoHostname xerrors.Error = "no host name available (IP only)"
+)
+
+// Options are the options for creating a new Jar.
+type Options struct {
+	// PublicSuffixList is the public suffix list that determines whether an
+	// HTTP server can set a cookie for a domain. If nil, the list from
+	// golang.org/x/net/publicsuffix is used.
+	PublicSuffixList cookiejar.PublicSuffixList
+
+	// Filename is the file the jar is loaded from and saved to. If empty, the
+	// jar is memory-only and Load and Save are no-ops.
+	Filename string
+
+	// Format is the format of the file.
+	Format Format
+}
+
+// Jar is a persistent implementation of the http.CookieJar interface. It is
+// safe for concurrent use.
+//
+// Only persistent cookies, those with an expiry date, are saved to disk.
+// Session cookies are kept in memory and are lost when the jar is discarded.
+type Jar struct {
+	// psList is the public suffix list used to validate cookie domains.
+	psList cookiejar.PublicSuffixList
+
+	// entries maps an eTLD+1 to the cookies for that domain, keyed by their
+	// name, domain and path.
+	entries map[string]map[string]entry
+
+	// filename is the file the jar is loaded from and saved to.
+	filename string
+
+	// format is the format of the file.
+	format Format
+
+	// nextSeqNum is the sequence number assigned to the next new cookie,
+	// used to break ties when sorting cookies.
+	nextSeqNum uint64
+
+	// mu protects entries and nextSeqNum.
+	mu sync.Mutex
+}
+
+// Compile-time check to ensure Jar implements the http.CookieJar interface.
+var _ http.CookieJar = (*Jar)(nil)
+
+// entry is a single cookie stored in the jar.
+type entry struct {
+	Expires    time.Time `json:"expires"`
+	Creation   time.Time `json:"creation"`
+	LastAccess time.Time `json:"lastAccess"`
+	Name       string    `json:"name"`
+	Value      string    `json:"value"`
+	Domain     string    `json:"domain"`
+	Path       string    `json:"path"`
+	SameSite   string    `json:"sameSite,omitempty"`
+	Secure     bool      `json:"secure"`
+	HTTPOnly   bool      `json:"httpOnly"`
+	Persistent bool      `json:"persistent"`
+	HostOnly   bool      `json:"hostOnly"`
+
+	// seqNum is the sequence number of the cookie, used to break ties when
+	// sorting cookies.
+	seqNum uint64
+}
+
+// New returns a new Jar using the given options, loading any cookies already
+// saved to the file. A nil options value is equivalent to a zero Options,
+// creating a memory-only jar.
+func New(opts *Options) (*Jar, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	jar := &Jar{
+		psList:   opts.PublicSuffixList,
+		entries:  make(map[string]map[string]entry),
+		filename: opts.Filename,
+		format:   opts.Format,
+	}
+
+	if jar.psList == nil {
+		jar.psList = publicsuffix.List
+	}
+
+	if err := jar.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return jar, nil
+}
+
+// Cookies implements the Cookies method of the http.CookieJar interface.
+//
+// It returns an empty slice if the URL's scheme is not HTTP or HTTPS.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return []*http.Cookie{}
+	}
+
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return []*http.Cookie{}
+	}
+
+	var (
+		key   = jarKey(host, j.psList)
+		https = u.Scheme == "https"
+		path  = u.Path
+		now   = time.Now()
+	)
+
+	if path == "" {
+		path = "/"
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	submap := j.entries[key]
+	if submap == nil {
+		return []*http.Cookie{}
+	}
+
+	selected := make([]entry, 0, len(submap))
+
+	for id, e := range submap {
+		if e.Persistent && !e.Expires.After(now) {
+			delete(submap, id)
+
+			continue
+		}
+
+		if !e.shouldSend(https, host, path) {
+			continue
+		}
+
+		e.LastAccess = now
+		submap[id] = e
+		selected = append(selected, e)
+	}
+
+	if len(submap) == 0 {
+		delete(j.entries, key)
+	}
+
+	sort.Slice(selected, func(i, k int) bool {
+		switch {
+		case len(selected[i].Path) != len(selected[k].Path):
+			return len(selected[i].Path) > len(selected[k].Path)
+		case !selected[i].Creation.Equal(selected[k].Creation):
+			return selected[i].Creation.Before(selected[k].Creation)
+		default:
+			return selected[i].seqNum < selected[k].seqNum
+		}
+	})
+
+	cookies := make([]*http.Cookie, 0, len(selected))
+
+	for i := range selected {
+		cookies = append(cookies, &http.Cookie{
+			Name:  selected[i].Name,
+			Value: selected[i].Value,
+		})
+	}
+
+	return cookies
+}
+
+// SetCookies implements the SetCookies method of the http.CookieJar interface.
+//
+// It does nothing if the URL's scheme is not HTTP or HTTPS. Cookies with an
+// invalid domain attribute are ignored.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if len(cookies) == 0 || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return
+	}
+
+	var (
+		key     = jarKey(host, j.psList)
+		defPath = defaultPath(u.Path)
+		now     = time.Now()
+	)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	submap := j.entries[key]
+
+	for _, cookie := range cookies {
+		e, remove, err := j.newEntry(cookie, now, defPath, host)
+		if err != nil {
+			continue
+		}
+
+		id := e.id()
+
+		if remove {
+			if submap != nil {
+				delete(submap, id)
+			}
+
+			continue
+		}
+
+		if submap == nil {
+			submap = make(map[string]entry)
+		}
+
+		if old, ok := submap[id]; ok {
+			e.Creation = old.Creation
+			e.seqNum = old.seqNum
+		} else {
+			e.Creation = now
+			e.seqNum = j.nextSeqNum
+			j.nextSeqNum++
+		}
+
+		e.LastAccess = now
+		submap[id] = e
+	}
+
+	if len(submap) == 0 {
+		delete(j.entries, key)
+	} else {
+		j.entries[key] = submap
+	}
+}
+
+// add stores an entry loaded from disk, unless it has expired or has an
+// invalid domain. The caller must hold j.mu.
+func (j *Jar) add(e *entry, now time.Time) {
+	if e.Persistent && !e.Expires.After(now) {
+		return
+	}
+
+	if !j.validEntry(e) {
+		return
+	}
+
+	key := jarKey(e.Domain, j.psList)
+
+	submap := j.entries[key]
+	if submap == nil {
+		submap = make(map[string]entry)
+		j.entries[key] = submap
+	}
+
+	if e.Creation.IsZero() {
+		e.Creation = now
+	}
+
+	if e.LastAccess.IsZero() {
+		e.LastAccess = now
+	}
+
+	e.seqNum = j.nextSeqNum
+	j.nextSeqNum++
+
+	submap[e.id()] = *e
+}
+
+// validEntry canonicalizes the domain and path of an entry loaded from disk
+// and reports whether SetCookies could have stored it, applying the same
+// domain and public suffix rules.
+func (j *Jar) validEntry(e *entry) bool {
+	domain, err := canonicalHost(e.Domain)
+	if err != nil || domain == "" || domain[0] == '.' {
+		return false
+	}
+
+	e.Domain = domain
+
+	if e.Path == "" || e.Path[0] != '/' {
+		return false
+	}
+
+	if e.HostOnly {
+		return true
+	}
+
+	if net.ParseIP(domain) != nil {
+		return false
+	}
+
+	suffix := j.psList.PublicSuffix(domain)
+
+	return suffix == "" || hasDotSuffix(domain, suffix)
+}
+
+// newEntry creates an entry from an http.Cookie set by the given host. The
+// returned remove flag indicates that the cookie should be deleted instead.
+func (j *Jar) newEntry(cookie *http.Cookie, now time.Time, defPath, host string) (e entry, remove bool, err error) {
+	e.Name = cookie.Name
+
+	if cookie.Path == "" || cookie.Path[0] != '/' {
+		e.Path = defPath
+	} else {
+		e.Path = cookie.Path
+	}
+
+	e.Domain, e.HostOnly, err = j.domainAndType(host, cookie.Domain)
+	if err != nil {
+		return e, false, err
+	}
+
+	switch {
+	case cookie.MaxAge < 0:
+		return e, true, nil
+	case cookie.MaxAge > 0:
+		e.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		e.Persistent = true
+	case !cookie.Expires.IsZero():
+		if !cookie.Expires.After(now) {
+			return e, true, nil
+		}
+
+		e.Expires = cookie.Expires
+		e.Persistent = true
+	}
+
+	e.Value = cookie.Value
+	e.Secure = cookie.Secure
+	e.HTTPOnly = cookie.HttpOnly
+
+	switch cookie.SameSite {
+	case http.SameSiteDefaultMode:
+		e.SameSite = "SameSite"
+	case http.SameSiteStrictMode:
+		e.SameSite = "SameSite=Strict"
+	case http.SameSiteLaxMode:
+		e.SameSite = "SameSite=Lax"
+	case http.SameSiteNoneMode:
+		e.SameSite = ""
+	}
+
+	return e, false, nil
+}
+
+// domainAndType determines the cookie's domain and whether it is a host-only
+// cookie, as described in RFC 6265, section 5.3, steps 4 to 6.
+func (j *Jar) domainAndType(host, domain string) (string, bool, error) {
+	if domain == "" {
+		return host, true, nil
+	}
+
+	if net.ParseIP(host) != nil {
+		if host != domain {
+			return "", false, ErrNoHostname
+		}
+
+		return host, true, nil
+	}
+
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+
+	if domain == "" || domain[0] == '.' || domain[len(domain)-1] == '.' {
+		return "", false, ErrMalformedDomain
+	}
+
+	if suffix := j.psList.PublicSuffix(domain); suffix != "" && !hasDotSuffix(domain, suffix) {
+		if host == domain {
+			return host, true, nil
+		}
+
+		return "", false, ErrIllegalDomain
+	}
+
+	if host != domain && !hasDotSuffix(host, domain) {
+		return "", false, ErrIllegalDomain
+	}
+
+	return domain, false, nil
+}
+
+// id returns the identifier of the entry within its domain.
+func (e *entry) id() string {
+	return fmt.Sprintf("%s;%s;%s", e.Domain, e.Path, e.Name)
+}
+
+// shouldSend reports whether the entry should be sent in a request to the
+// given host and path.
+func (e *entry) shouldSend(https bool, host, path string) bool {
+	return e.domainMatch(host) && e.pathMatch(path) && (https || !e.Secure)
+}
+
+// domainMatch implements domain-match as defined in RFC 6265, section 5.1.3.
+func (e *entry) domainMatch(host string) bool {
+	if e.Domain == host {
+		return true
+	}
+
+	return !e.HostOnly && hasDotSuffix(host, e.Domain)
+}
+
+// pathMatch implements path-match as defined in RFC 6265, section 5.1.4.
+func (e *entry) pathMatch(requestPath string) bool {
+	if requestPath == e.Path {
+		return true
+	}
+
+	if strings.HasPrefix(requestPath, e.Path) {
+		if e.Path[len(e.Path)-1] == '/' {
+			return true
+		}
+
+		if requestPath[len(e.Path)] == '/' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// canonicalHost strips the port from host, if present, and returns the
+// lowercase host without a trailing dot.
+func canonicalHost(host string) (string, error) {
+	if hasPort(host) {
+		h, _, err := net.SplitHostPort(host)
+		if err != nil {
+			return "", fmt.Errorf("%w", err)
+		}
+
+		host = h
+	}
+
+	host = strings.TrimSuffix(host, ".")
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	return strings.ToLower(host), nil
+}
+
+// hasPort reports whether host contains a port number.
+func hasPort(host string) bool {
+	colons := strings.Count(host, ":")
+
+	switch {
+	case colons == 0:
+		return false
+	case colons == 1:
+		return true
+	default:
+		return host[0] == '[' && strings.Contains(host, "]:")
+	}
+}
+
+// jarKey returns the key under which cookies for the given host are stored,
+// which is the host's eTLD+1, or the host itself for IP addresses and public
+// suffixes.
+func jarKey(host string, psList cookiejar.PublicSuffixList) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	suffix := psList.PublicSuffix(host)
+	if suffix == host {
+		return host
+	}
+
+	i := len(host) - len(suffix)
+	if i <= 0 || host[i-1] != '.' {
+		return host
+	}
+
+	prevDot := strings.LastIndex(host[:i-1], ".")
+
+	return host[prevDot+1:]
+}
+
+// hasDotSuffix reports whether s ends in "."+suffix.
+func hasDotSuffix(s, suffix string) bool {
+	return len(s) > len(suffix) && s[len(s)-len(suffix)-1] == '.' && s[len(s)-len(suffix):] == suffix
+}
+
+// defaultPath returns the directory part of a URL's path, as defined in RFC
+// 6265, section 5.1.4.
+func defaultPath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
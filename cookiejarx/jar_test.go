@@ -0,0 +1,220 @@
+package cookiejarx_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go/cookiejarx"
+)
+
+func TestJar_SetCookies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		setURL  string
+		cookie  *http.Cookie
+		getURL  string
+		wantLen int
+	}{
+		{
+			name:    "host-only cookie",
+			setURL:  "https://www.example.com/",
+			cookie:  &http.Cookie{Name: "a", Value: "1"},
+			getURL:  "https://www.example.com/",
+			wantLen: 1,
+		},
+		{
+			name:    "host-only cookie not sent to subdomain",
+			setURL:  "https://example.com/",
+			cookie:  &http.Cookie{Name: "a", Value: "1"},
+			getURL:  "https://www.example.com/",
+			wantLen: 0,
+		},
+		{
+			name:    "domain cookie sent to subdomain",
+			setURL:  "https://example.com/",
+			cookie:  &http.Cookie{Name: "a", Value: "1", Domain: "example.com"},
+			getURL:  "https://www.example.com/",
+			wantLen: 1,
+		},
+		{
+			name:    "public suffix domain rejected",
+			setURL:  "https://www.example.co.uk/",
+			cookie:  &http.Cookie{Name: "a", Value: "1", Domain: "co.uk"},
+			getURL:  "https://other.co.uk/",
+			wantLen: 0,
+		},
+		{
+			name:    "unrelated domain rejected",
+			setURL:  "https://example.com/",
+			cookie:  &http.Cookie{Name: "a", Value: "1", Domain: "example.org"},
+			getURL:  "https://example.org/",
+			wantLen: 0,
+		},
+		{
+			name:    "secure cookie not sent over HTTP",
+			setURL:  "https://example.com/",
+			cookie:  &http.Cookie{Name: "a", Value: "1", Secure: true},
+			getURL:  "http://example.com/",
+			wantLen: 0,
+		},
+		{
+			name:    "path mismatch",
+			setURL:  "https://example.com/",
+			cookie:  &http.Cookie{Name: "a", Value: "1", Path: "/admin"},
+			getURL:  "https://example.com/administrator",
+			wantLen: 0,
+		},
+		{
+			name:    "expired cookie",
+			setURL:  "https://example.com/",
+			cookie:  &http.Cookie{Name: "a", Value: "1", Expires: time.Now().Add(-time.Hour)},
+			getURL:  "https://example.com/",
+			wantLen: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			jar, err := cookiejarx.New(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jar.SetCookies(mustParse(t, tt.setURL), []*http.Cookie{tt.cookie})
+
+			if got := jar.Cookies(mustParse(t, tt.getURL)); len(got) != tt.wantLen {
+				t.Errorf("Cookies() returned %d cookies, want %d", len(got), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestJar_SaveLoad(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		format cookiejarx.Format
+	}{
+		{
+			name:   "JSON",
+			format: cookiejarx.FormatJSON,
+		},
+		{
+			name:   "Netscape",
+			format: cookiejarx.FormatNetscape,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := &cookiejarx.Options{
+				Filename: filepath.Join(t.TempDir(), "cookies"),
+				Format:   tt.format,
+			}
+
+			jar, err := cookiejarx.New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			u := mustParse(t, "https://example.com/")
+
+			jar.SetCookies(u, []*http.Cookie{
+				{Name: "persistent", Value: "1", MaxAge: 3600, HttpOnly: true},
+				{Name: "domain", Value: "2", Domain: "example.com", Expires: time.Now().Add(time.Hour)},
+				{Name: "session", Value: "3"},
+				{Name: "empty", Value: "", MaxAge: 3600},
+			})
+
+			if err = jar.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := cookiejarx.New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string]string)
+			for _, cookie := range loaded.Cookies(mustParse(t, "https://www.example.com/")) {
+				got[cookie.Name] = cookie.Value
+			}
+
+			if len(got) != 1 || got["domain"] != "2" {
+				t.Errorf("Cookies() for subdomain = %v, want only the domain cookie", got)
+			}
+
+			got = make(map[string]string)
+			for _, cookie := range loaded.Cookies(u) {
+				got[cookie.Name] = cookie.Value
+			}
+
+			if _, ok := got["empty"]; !ok || len(got) != 3 || got["persistent"] != "1" || got["domain"] != "2" {
+				t.Errorf("Cookies() = %v, want the persistent cookies only", got)
+			}
+		})
+	}
+}
+
+func TestJar_LoadPublicSuffix(t *testing.T) {
+	t.Parallel()
+
+	var (
+		filename = filepath.Join(t.TempDir(), "cookies.txt")
+		expires  = time.Now().Add(time.Hour).Unix()
+		data     = fmt.Sprintf(
+			".com\tTRUE\t/\tFALSE\t%d\tsuffix\t1\n"+
+				".example.com\tTRUE\t/\tFALSE\t%d\tdomain\t2\n"+
+				"example.com\tFALSE\tpath\tFALSE\t%d\tpath\t3\n",
+			expires, expires, expires,
+		)
+	)
+
+	if err := os.WriteFile(filename, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	jar, err := cookiejarx.New(&cookiejarx.Options{
+		Filename: filename,
+		Format:   cookiejarx.FormatNetscape,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := jar.Cookies(mustParse(t, "https://com/")); len(got) != 0 {
+		t.Errorf("Cookies() for public suffix = %v, want none", got)
+	}
+
+	got := jar.Cookies(mustParse(t, "https://example.com/"))
+	if len(got) != 1 || got[0].Name != "domain" {
+		t.Errorf("Cookies() = %v, want only the domain cookie", got)
+	}
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
@@ -0,0 +1,371 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// _defaultFallbackDelay is the delay between connection attempts used when
+// Dialer.FallbackDelay is zero, matching [net.Dialer].
+//
+// [net.Dialer]: https://godocs.io/net#Dialer
+const _defaultFallbackDelay = 300 * time.Millisecond
+
+// Dialer dials network connections, resolving hosts with a custom Resolver
+// such as DNSCache and racing connection attempts to the resolved addresses
+// following the Happy Eyeballs algorithm described in RFC 8305.
+//
+// Addresses are tried alternating between IPv6 and IPv4, starting with the
+// family of the first resolved address, with a new attempt started every
+// FallbackDelay or as soon as the previous one fails. The first connection to
+// succeed is used and the others are cancelled.
+//
+// The zero value is ready to use.
+type Dialer struct {
+	// failures holds when each address last failed to connect, by host.
+	failures map[dialKey]time.Time
+
+	// Resolver is the resolver used to look up hosts. If nil,
+	// [net.DefaultResolver] is used.
+	//
+	// [net.DefaultResolver]: https://godocs.io/net#DefaultResolver
+	Resolver Resolver
+
+	// Timeout is the maximum amount of time a dial will wait for a connection
+	// to complete, across all resolved addresses. Zero means no timeout.
+	Timeout time.Duration
+
+	// KeepAlive is the interval between keep-alive probes for active network
+	// connections. A negative value disables keep-alive probes.
+	KeepAlive time.Duration
+
+	// FallbackDelay is how long to wait for a connection attempt before
+	// starting the next one in parallel. If zero, a default delay of 300ms is
+	// used. A negative value disables racing, trying addresses one at a time.
+	FallbackDelay time.Duration
+
+	// UnixSockets maps hosts to the paths of Unix domain sockets. Connections
+	// to those hosts are made over the socket, regardless of port, instead of
+	// TCP.
+	UnixSockets map[string]string
+
+	// FailureCooldown is how long an address that failed to connect is
+	// skipped when dialing its host, as long as the host has other addresses
+	// that did not fail. Zero disables remembering failed addresses.
+	FailureCooldown time.Duration
+
+	// mu protects failures.
+	mu sync.Mutex
+}
+
+// dialKey identifies an address of a host.
+type dialKey struct {
+	host string
+	addr string
+}
+
+// dialResult is the outcome of a single connection attempt.
+type dialResult struct {
+	conn net.Conn
+	err  error
+	addr net.IPAddr
+}
+
+// DialContext connects to the address on the named network using the provided
+// context. It has the same signature as [net.Dialer.DialContext], so it can be
+// used as a [*http.Transport]'s DialContext.
+//
+// [net.Dialer.DialContext]: https://godocs.io/net#Dialer.DialContext
+// [*http.Transport]: https://godocs.io/net/http#Transport
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if path, ok := d.UnixSockets[host]; ok {
+		return dialUnix(ctx, path)
+	}
+
+	resolved, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	addrs := make([]net.IPAddr, 0, len(resolved))
+
+	for _, addr := range resolved {
+		if matchesNetwork(network, addr.IP) {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: &net.AddrError{Err: "no suitable address found", Addr: host}}
+	}
+
+	return d.dialParallel(ctx, network, host, port, d.order(host, addrs))
+}
+
+// dialParallel races connection attempts to the given addresses, starting a
+// new attempt every FallbackDelay or as soon as the previous one fails, and
+// returns the first connection to succeed.
+func (d *Dialer) dialParallel(ctx context.Context, network, host, port string, addrs []net.IPAddr) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		dialer   = &net.Dialer{KeepAlive: d.KeepAlive}
+		results  = make(chan dialResult, len(addrs))
+		timer    *time.Timer
+		fallback <-chan time.Time
+		next     int
+		pending  int
+		lastErr  error
+	)
+
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	launch := func() {
+		addr := addrs[next]
+
+		next++
+		pending++
+
+		go func() {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+
+			results <- dialResult{conn: conn, err: err, addr: addr}
+		}()
+
+		if timer != nil {
+			timer.Stop()
+		}
+
+		fallback = nil
+
+		if delay := d.fallbackDelay(); delay > 0 && next < len(addrs) {
+			timer = time.NewTimer(delay)
+			fallback = timer.C
+		}
+	}
+
+	launch()
+
+	for pending > 0 {
+		select {
+		case result := <-results:
+			pending--
+
+			if result.err == nil {
+				d.recordSuccess(host, result.addr)
+
+				cancel()
+				closeLateConns(results, pending)
+
+				return result.conn, nil
+			}
+
+			lastErr = result.err
+
+			if ctx.Err() == nil {
+				d.recordFailure(host, result.addr)
+			}
+
+			if next < len(addrs) && ctx.Err() == nil {
+				launch()
+			}
+		case <-fallback:
+			launch()
+		}
+	}
+
+	return nil, fmt.Errorf("%w", lastErr)
+}
+
+// fallbackDelay returns the delay between connection attempts.
+func (d *Dialer) fallbackDelay() time.Duration {
+	if d.FallbackDelay == 0 {
+		return _defaultFallbackDelay
+	}
+
+	return d.FallbackDelay
+}
+
+// order returns the addresses in the order they should be tried, alternating
+// between address families and without the recently failed addresses, unless
+// all of them failed recently.
+func (d *Dialer) order(host string, addrs []net.IPAddr) []net.IPAddr {
+	healthy := make([]net.IPAddr, 0, len(addrs))
+
+	for _, addr := range addrs {
+		if !d.coolingDown(host, addr) {
+			healthy = append(healthy, addr)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return interleave(addrs)
+	}
+
+	return interleave(healthy)
+}
+
+// coolingDown reports whether the address failed to connect within the last
+// FailureCooldown.
+func (d *Dialer) coolingDown(host string, addr net.IPAddr) bool {
+	if d.FailureCooldown <= 0 {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := dialKey{host: host, addr: addr.String()}
+
+	failedAt, ok := d.failures[key]
+	if !ok {
+		return false
+	}
+
+	if time.Since(failedAt) >= d.FailureCooldown {
+		delete(d.failures, key)
+
+		return false
+	}
+
+	return true
+}
+
+// recordFailure remembers that the address failed to connect.
+func (d *Dialer) recordFailure(host string, addr net.IPAddr) {
+	if d.FailureCooldown <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.failures == nil {
+		d.failures = make(map[dialKey]time.Time)
+	}
+
+	d.failures[dialKey{host: host, addr: addr.String()}] = time.Now()
+}
+
+// recordSuccess forgets any previous failure of the address.
+func (d *Dialer) recordSuccess(host string, addr net.IPAddr) {
+	if d.FailureCooldown <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.failures, dialKey{host: host, addr: addr.String()})
+}
+
+// resolve returns the addresses of the given host, which may already be an IP
+// address.
+func (d *Dialer) resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IPAddr{{IP: ip}}, nil
+	}
+
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return addrs, nil
+}
+
+// closeLateConns closes the connections of attempts still pending once another
+// attempt has won the race.
+func closeLateConns(results <-chan dialResult, pending int) {
+	if pending == 0 {
+		return
+	}
+
+	go func() {
+		for ; pending > 0; pending-- {
+			if result := <-results; result.conn != nil {
+				result.conn.Close()
+			}
+		}
+	}()
+}
+
+// interleave reorders the addresses to alternate between address families,
+// starting with the family of the first address, as recommended by RFC 8305.
+func interleave(addrs []net.IPAddr) []net.IPAddr {
+	if len(addrs) < 2 {
+		return addrs
+	}
+
+	var (
+		primary  []net.IPAddr
+		fallback []net.IPAddr
+		isIPv4   = addrs[0].IP.To4() != nil
+	)
+
+	for _, addr := range addrs {
+		if (addr.IP.To4() != nil) == isIPv4 {
+			primary = append(primary, addr)
+
+			continue
+		}
+
+		fallback = append(fallback, addr)
+	}
+
+	ordered := make([]net.IPAddr, 0, len(addrs))
+
+	for i := 0; i < len(primary) || i < len(fallback); i++ {
+		if i < len(primary) {
+			ordered = append(ordered, primary[i])
+		}
+
+		if i < len(fallback) {
+			ordered = append(ordered, fallback[i])
+		}
+	}
+
+	return ordered
+}
+
+// matchesNetwork reports whether the IP address can be dialed on the named
+// network.
+func matchesNetwork(network string, ip net.IP) bool {
+	switch network {
+	case "tcp4", "udp4", "ip4":
+		return ip.To4() != nil
+	case "tcp6", "udp6", "ip6":
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
@@ -0,0 +1,168 @@
+package httpx_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+)
+
+func TestDialer_DialContext(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go acceptConns(listener)
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 192.0.2.1 is reserved for documentation and never answers, so without
+	// racing the dial would block until the timeout.
+	dialer := &httpx.Dialer{
+		Resolver: &stubResolver{
+			addrs: []net.IPAddr{
+				{IP: net.ParseIP("192.0.2.1")},
+				{IP: net.ParseIP("127.0.0.1")},
+			},
+		},
+		Timeout:       5 * time.Second,
+		FallbackDelay: 10 * time.Millisecond,
+	}
+
+	start := time.Now()
+
+	conn, err := dialer.DialContext(context.Background(), "tcp", net.JoinHostPort("example.com", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dial took %s, expected the fallback address to win the race", elapsed)
+	}
+
+	if got := conn.RemoteAddr().(*net.TCPAddr).IP.String(); got != "127.0.0.1" {
+		t.Errorf("got remote address %s, want 127.0.0.1", got)
+	}
+}
+
+func TestDialer_FailureCooldown(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		cooldown     time.Duration
+		closePrimary bool
+		want         string
+	}{
+		{
+			name:     "failed address skipped",
+			cooldown: time.Minute,
+			want:     "127.0.0.1",
+		},
+		{
+			name:         "failed address not raced",
+			cooldown:     time.Minute,
+			closePrimary: true,
+		},
+		{
+			name:     "cooldown disabled",
+			cooldown: 0,
+			want:     "127.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			primary, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { primary.Close() })
+
+			go acceptConns(primary)
+
+			_, port, err := net.SplitHostPort(primary.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dialer := &httpx.Dialer{
+				Resolver: &stubResolver{
+					addrs: []net.IPAddr{
+						{IP: net.ParseIP("127.0.0.2")},
+						{IP: net.ParseIP("127.0.0.1")},
+					},
+				},
+				FallbackDelay:   -1,
+				FailureCooldown: tt.cooldown,
+			}
+
+			address := net.JoinHostPort("example.com", port)
+
+			// Nothing listens on 127.0.0.2 yet, so the first dial fails over.
+			conn, err := dialer.DialContext(context.Background(), "tcp", address)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conn.Close()
+
+			secondary, err := net.Listen("tcp", net.JoinHostPort("127.0.0.2", port))
+			if err != nil {
+				t.Skipf("cannot listen on 127.0.0.2: %v", err)
+			}
+			t.Cleanup(func() { secondary.Close() })
+
+			go acceptConns(secondary)
+
+			if tt.closePrimary {
+				primary.Close()
+			}
+
+			conn, err = dialer.DialContext(context.Background(), "tcp", address)
+			if tt.want == "" {
+				if err == nil {
+					conn.Close()
+					t.Fatal("expected error, the failed address must not be tried")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			if got := conn.RemoteAddr().(*net.TCPAddr).IP.String(); got != tt.want {
+				t.Errorf("got remote address %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// acceptConns accepts and closes connections until the listener is closed.
+func acceptConns(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		conn.Close()
+	}
+}
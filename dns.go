@@ -0,0 +1,246 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Resolver defines the interface for looking up the IP addresses of a host.
+// [*net.Resolver] implements this interface.
+//
+// [*net.Resolver]: https://godocs.io/net#Resolver
+type Resolver interface {
+	// LookupIPAddr looks up the IP addresses of the given host.
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// TTLResolver is a Resolver that also reports how long the returned addresses
+// may be cached for. DNSCache uses the reported TTL instead of its own when the
+// underlying resolver implements this interface.
+type TTLResolver interface {
+	Resolver
+
+	// LookupIPAddrTTL looks up the IP addresses of the given host and returns
+	// them along with the lowest TTL of the records.
+	LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error)
+}
+
+// DNSCache is an in-process Resolver that caches the results of another
+// Resolver, rotates through the cached addresses in round-robin order and
+// supports static host overrides. Concurrent lookups of the same host that is
+// not cached share a single lookup.
+//
+// The zero value is usable, but only caches lookups whose TTL is reported by
+// the resolver; use DefaultDNSCache for sensible defaults.
+type DNSCache struct {
+	// group collapses concurrent lookups of the same host.
+	group singleflight.Group
+
+	// entries holds the cached lookups for each host.
+	entries map[string]*dnsEntry
+
+	// Resolver is the resolver used for hosts that are not cached. If nil,
+	// [net.DefaultResolver] is used.
+	//
+	// [net.DefaultResolver]: https://godocs.io/net#DefaultResolver
+	Resolver Resolver
+
+	// Overrides maps lowercase hosts to static addresses that are returned
+	// without a lookup, similar to curl's --resolve flag.
+	Overrides map[string][]net.IP
+
+	// TTL is how long successful lookups are cached for when the resolver
+	// does not report a TTL, or reports an unknown TTL of zero.
+	TTL time.Duration
+
+	// NegativeTTL is how long failed lookups are cached for. Zero disables
+	// caching of failed lookups.
+	NegativeTTL time.Duration
+
+	// mu protects entries.
+	mu sync.Mutex
+}
+
+// dnsEntry holds a cached lookup for a single host.
+type dnsEntry struct {
+	// expires is when the entry must be looked up again.
+	expires time.Time
+
+	// err is the error returned by the lookup, if any.
+	err error
+
+	// addrs holds the addresses returned by the lookup.
+	addrs []net.IPAddr
+
+	// next is the index of the address returned first by the next call.
+	next int
+}
+
+// Compile-time check to ensure DNSCache implements the Resolver interface.
+var _ Resolver = (*DNSCache)(nil)
+
+// DefaultDNSCache returns a DNSCache with sensible defaults, caching the
+// lookups of [net.DefaultResolver] for a fixed TTL. To honor the TTL of DNS
+// records instead, set its Resolver to a DNSResolver, keeping in mind that it
+// does not apply the search domains of the system's configuration.
+//
+// [net.DefaultResolver]: https://godocs.io/net#DefaultResolver
+func DefaultDNSCache() *DNSCache {
+	return &DNSCache{
+		TTL:         60 * time.Second,
+		NegativeTTL: 5 * time.Second,
+	}
+}
+
+// LookupIPAddr implements the Resolver interface. Addresses are returned from
+// the overrides or the cache when possible, rotated so that consecutive calls
+// start with a different address.
+func (c *DNSCache) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	key := strings.ToLower(host)
+
+	if ips, ok := c.Overrides[key]; ok {
+		addrs := make([]net.IPAddr, 0, len(ips))
+
+		for _, ip := range ips {
+			addrs = append(addrs, net.IPAddr{IP: ip})
+		}
+
+		return addrs, nil
+	}
+
+	c.mu.Lock()
+
+	entry, ok := c.entries[key]
+	if ok && time.Now().Before(entry.expires) {
+		addrs, err := entry.rotate(host)
+
+		c.mu.Unlock()
+
+		return addrs, err
+	}
+
+	c.mu.Unlock()
+
+	// The shared lookup must not be canceled by the first caller, so it runs
+	// without a deadline of its own and each caller waits on its own context.
+	ch := c.group.DoChan(key, func() (any, error) {
+		return c.resolve(host, key), nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w", ctx.Err())
+	case result := <-ch:
+		entry, _ = result.Val.(*dnsEntry)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		return entry.rotate(host)
+	}
+}
+
+// resolve looks up the host and caches the result under key, unless the
+// lookup failed temporarily.
+func (c *DNSCache) resolve(host, key string) *dnsEntry {
+	addrs, ttl, err := c.lookup(context.Background(), host)
+	if err != nil && (c.NegativeTTL <= 0 || isTemporaryDNSError(err)) {
+		return &dnsEntry{err: err}
+	}
+
+	if err != nil {
+		ttl = c.NegativeTTL
+	}
+
+	entry := &dnsEntry{
+		expires: time.Now().Add(ttl),
+		err:     err,
+		addrs:   addrs,
+	}
+
+	if ttl <= 0 {
+		return entry
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]*dnsEntry)
+	}
+
+	c.entries[key] = entry
+
+	return entry
+}
+
+// Clear removes all cached lookups.
+func (c *DNSCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = nil
+}
+
+// lookup looks up the host using the underlying resolver.
+func (c *DNSCache) lookup(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	resolver := c.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	if r, ok := resolver.(TTLResolver); ok {
+		addrs, ttl, err := r.LookupIPAddrTTL(ctx, host)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w", err)
+		}
+
+		if ttl <= 0 {
+			ttl = c.TTL
+		}
+
+		return addrs, ttl, nil
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w", err)
+	}
+
+	return addrs, c.TTL, nil
+}
+
+// rotate returns a copy of the entry's addresses starting at the next address
+// in round-robin order, or the entry's error.
+func (e *dnsEntry) rotate(host string) ([]net.IPAddr, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	if len(e.addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	addrs := make([]net.IPAddr, 0, len(e.addrs))
+	addrs = append(addrs, e.addrs[e.next:]...)
+	addrs = append(addrs, e.addrs[:e.next]...)
+
+	e.next = (e.next + 1) % len(e.addrs)
+
+	return addrs, nil
+}
+
+// isTemporaryDNSError reports whether err is a DNS error that should not be
+// cached.
+func isTemporaryDNSError(err error) bool {
+	var dnsErr *net.DNSError
+
+	return errors.As(err, &dnsErr) && (dnsErr.IsTimeout || dnsErr.IsTemporary)
+}
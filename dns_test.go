@@ -0,0 +1,455 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubResolver is a Resolver that returns fixed results and counts lookups.
+type stubResolver struct {
+	err     error
+	addrs   []net.IPAddr
+	ttl     time.Duration
+	delay   time.Duration
+	lookups int
+	mu      sync.Mutex
+}
+
+func (r *stubResolver) LookupIPAddr(_ context.Context, _ string) ([]net.IPAddr, error) {
+	r.mu.Lock()
+	r.lookups++
+	r.mu.Unlock()
+
+	time.Sleep(r.delay)
+
+	return r.addrs, r.err
+}
+
+func (r *stubResolver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lookups
+}
+
+// ttlResolver is a stubResolver that reports a TTL.
+type ttlResolver struct {
+	stubResolver
+}
+
+func (r *ttlResolver) LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	addrs, err := r.LookupIPAddr(ctx, host)
+
+	return addrs, r.ttl, err
+}
+
+func TestDNSCache_LookupIPAddr(t *testing.T) {
+	t.Parallel()
+
+	addrs := []net.IPAddr{
+		{IP: net.ParseIP("192.0.2.1")},
+		{IP: net.ParseIP("192.0.2.2")},
+		{IP: net.ParseIP("2001:db8::1")},
+	}
+
+	t.Run("caches and rotates", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := &stubResolver{addrs: addrs}
+
+		cache := httpx.DefaultDNSCache()
+		cache.Resolver = resolver
+
+		for i := 0; i < len(addrs)+1; i++ {
+			got, err := cache.LookupIPAddr(context.Background(), "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := addrs[i%len(addrs)]; !got[0].IP.Equal(want.IP) {
+				t.Errorf("lookup %d: got first address %s, want %s", i, got[0], want)
+			}
+
+			if len(got) != len(addrs) {
+				t.Errorf("lookup %d: got %d addresses, want %d", i, len(got), len(addrs))
+			}
+		}
+
+		if got := resolver.count(); got != 1 {
+			t.Errorf("got %d lookups, want 1", got)
+		}
+	})
+
+	t.Run("expires after TTL", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := &stubResolver{addrs: addrs}
+
+		cache := httpx.DefaultDNSCache()
+		cache.Resolver = resolver
+		cache.TTL = time.Millisecond
+
+		for i := 0; i < 2; i++ {
+			if _, err := cache.LookupIPAddr(context.Background(), "example.com"); err != nil {
+				t.Fatal(err)
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		if got := resolver.count(); got != 2 {
+			t.Errorf("got %d lookups, want 2", got)
+		}
+	})
+
+	t.Run("honors resolver TTL", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := &ttlResolver{stubResolver{addrs: addrs, ttl: time.Millisecond}}
+
+		cache := httpx.DefaultDNSCache()
+		cache.Resolver = resolver
+		cache.TTL = time.Hour
+
+		for i := 0; i < 2; i++ {
+			if _, err := cache.LookupIPAddr(context.Background(), "example.com"); err != nil {
+				t.Fatal(err)
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		if got := resolver.count(); got != 2 {
+			t.Errorf("got %d lookups, want 2", got)
+		}
+	})
+
+	t.Run("zero value", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := &ttlResolver{stubResolver{addrs: addrs}}
+
+		cache := &httpx.DNSCache{Resolver: resolver, TTL: time.Hour}
+
+		for i := 0; i < 2; i++ {
+			if _, err := cache.LookupIPAddr(context.Background(), "example.com"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := resolver.count(); got != 1 {
+			t.Errorf("got %d lookups, want 1", got)
+		}
+	})
+
+	t.Run("collapses concurrent lookups", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := &stubResolver{addrs: addrs, delay: 50 * time.Millisecond}
+
+		cache := httpx.DefaultDNSCache()
+		cache.Resolver = resolver
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				if _, err := cache.LookupIPAddr(context.Background(), "example.com"); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		if got := resolver.count(); got != 1 {
+			t.Errorf("got %d lookups, want 1", got)
+		}
+	})
+
+	t.Run("caches failures", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := &stubResolver{err: &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}}
+
+		cache := httpx.DefaultDNSCache()
+		cache.Resolver = resolver
+
+		for i := 0; i < 2; i++ {
+			var dnsErr *net.DNSError
+
+			_, err := cache.LookupIPAddr(context.Background(), "example.com")
+			if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+				t.Fatalf("got error %v, want not found error", err)
+			}
+		}
+
+		if got := resolver.count(); got != 1 {
+			t.Errorf("got %d lookups, want 1", got)
+		}
+	})
+
+	t.Run("does not cache temporary failures", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := &stubResolver{err: &net.DNSError{Err: "timeout", Name: "example.com", IsTimeout: true}}
+
+		cache := httpx.DefaultDNSCache()
+		cache.Resolver = resolver
+
+		for i := 0; i < 2; i++ {
+			if _, err := cache.LookupIPAddr(context.Background(), "example.com"); err == nil {
+				t.Fatal("expected error")
+			}
+		}
+
+		if got := resolver.count(); got != 2 {
+			t.Errorf("got %d lookups, want 2", got)
+		}
+	})
+
+	t.Run("uses overrides", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := &stubResolver{addrs: addrs}
+
+		cache := httpx.DefaultDNSCache()
+		cache.Resolver = resolver
+		cache.Overrides = map[string][]net.IP{"api.example.com": {net.ParseIP("203.0.113.7")}}
+
+		got, err := cache.LookupIPAddr(context.Background(), "API.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != 1 || !got[0].IP.Equal(net.ParseIP("203.0.113.7")) {
+			t.Errorf("got %v, want [203.0.113.7]", got)
+		}
+
+		if got := resolver.count(); got != 0 {
+			t.Errorf("got %d lookups, want 0", got)
+		}
+	})
+}
+
+func TestDNSResolver_LookupIPAddrTTL(t *testing.T) {
+	t.Parallel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go serveDNS(conn)
+
+	fallback := &stubResolver{addrs: []net.IPAddr{{IP: net.ParseIP("192.0.2.9")}}}
+
+	resolver := &httpx.DNSResolver{
+		Fallback: fallback,
+		Servers:  []string{conn.LocalAddr().String()},
+		Timeout:  time.Second,
+	}
+
+	t.Run("reports the lowest TTL", func(t *testing.T) {
+		t.Parallel()
+
+		addrs, ttl, err := resolver.LookupIPAddrTTL(context.Background(), "www.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(addrs) != 1 || !addrs[0].IP.Equal(net.ParseIP("192.0.2.1")) {
+			t.Errorf("got %v, want [192.0.2.1]", addrs)
+		}
+
+		if ttl != 42*time.Second {
+			t.Errorf("got TTL %s, want 42s", ttl)
+		}
+	})
+
+	t.Run("ignores a failed address family", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := &httpx.DNSResolver{
+			Servers: []string{conn.LocalAddr().String()},
+			Timeout: 100 * time.Millisecond,
+		}
+
+		addrs, ttl, err := resolver.LookupIPAddrTTL(context.Background(), "v4only.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(addrs) != 1 || !addrs[0].IP.Equal(net.ParseIP("192.0.2.4")) {
+			t.Errorf("got %v, want [192.0.2.4]", addrs)
+		}
+
+		if ttl != 60*time.Second {
+			t.Errorf("got TTL %s, want 1m0s", ttl)
+		}
+	})
+
+	t.Run("falls back for unknown hosts", func(t *testing.T) {
+		t.Parallel()
+
+		addrs, ttl, err := resolver.LookupIPAddrTTL(context.Background(), "missing.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(addrs) != 1 || !addrs[0].IP.Equal(net.ParseIP("192.0.2.9")) {
+			t.Errorf("got %v, want [192.0.2.9]", addrs)
+		}
+
+		if ttl != 0 {
+			t.Errorf("got TTL %s, want 0", ttl)
+		}
+	})
+}
+
+// serveDNS answers A queries for www.example.com with a CNAME to
+// example.com and an A record, A queries for v4only.example.com with an A
+// record while never answering its AAAA queries, and every other query with
+// NXDOMAIN.
+func serveDNS(conn net.PacketConn) {
+	buf := make([]byte, 512)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		var parser dnsmessage.Parser
+
+		header, err := parser.Start(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		question, err := parser.Question()
+		if err != nil {
+			continue
+		}
+
+		header.Response = true
+
+		name := question.Name.String()
+
+		if name == "v4only.example.com." && question.Type == dnsmessage.TypeAAAA {
+			continue
+		}
+
+		if name != "www.example.com." && name != "v4only.example.com." {
+			header.RCode = dnsmessage.RCodeNameError
+		}
+
+		builder := dnsmessage.NewBuilder(nil, header)
+
+		if builder.StartQuestions() != nil || builder.Question(question) != nil || builder.StartAnswers() != nil {
+			continue
+		}
+
+		if name == "v4only.example.com." {
+			if builder.AResource(
+				dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 60},
+				dnsmessage.AResource{A: [4]byte{192, 0, 2, 4}},
+			) != nil {
+				continue
+			}
+		} else if header.RCode == dnsmessage.RCodeSuccess {
+			target := dnsmessage.MustNewName("example.com.")
+
+			if builder.CNAMEResource(
+				dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 300},
+				dnsmessage.CNAMEResource{CNAME: target},
+			) != nil {
+				continue
+			}
+
+			if question.Type == dnsmessage.TypeA && builder.AResource(
+				dnsmessage.ResourceHeader{Name: target, Class: dnsmessage.ClassINET, TTL: 42},
+				dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+			) != nil {
+				continue
+			}
+		}
+
+		msg, err := builder.Finish()
+		if err != nil {
+			continue
+		}
+
+		if _, err = conn.WriteTo(msg, addr); err != nil {
+			return
+		}
+	}
+}
+
+func TestClient_DNSCache(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Host", r.Host)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing listens on 127.0.0.2, so the dialer must fail over to the next
+	// address.
+	resolver := &stubResolver{
+		addrs: []net.IPAddr{
+			{IP: net.ParseIP("127.0.0.2")},
+			{IP: net.ParseIP(serverURL.Hostname())},
+		},
+	}
+
+	cache := httpx.DefaultDNSCache()
+	cache.Resolver = resolver
+
+	transport, err := httpx.NewTransport(httpx.WithResolver(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := httpx.NewClient()
+	client.Transport = transport
+	client.RetryPolicy = nil
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(context.Background(), "http://api.example.com:"+serverURL.Port()+"/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+
+		if got, want := resp.Header.Get("X-Host"), "api.example.com:"+serverURL.Port(); got != want {
+			t.Errorf("got host %q, want %q", got, want)
+		}
+	}
+
+	if got := resolver.count(); got != 1 {
+		t.Errorf("got %d lookups, want 1", got)
+	}
+}
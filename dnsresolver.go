@@ -0,0 +1,476 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xcrypto/xrand"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// _resolvConf is the path of the system's resolver configuration.
+	_resolvConf string = "/etc/resolv.conf"
+
+	// _dnsPort is the port DNS servers listen on.
+	_dnsPort string = "53"
+
+	// _dnsUDPSize is the size of the buffer used to read UDP responses.
+	_dnsUDPSize int = 1232
+)
+
+// DNSResolver is a TTLResolver that queries DNS servers directly, so that the
+// TTL of the records returned can be honored by a DNSCache.
+//
+// DNSResolver is a minimal DNS client and behaves differently from the
+// system's resolver: it does not read the hosts file and ignores the search
+// domains and options, such as ndots, of the system's configuration. Entries
+// of the hosts file are only honored for hosts the DNS servers do not know,
+// which are looked up with Fallback instead. It is only used when set as a
+// DNSCache's Resolver.
+type DNSResolver struct {
+	// Fallback is the resolver used when no DNS servers are configured or the
+	// servers report that a host does not exist. Its results have no TTL. If
+	// nil, such lookups fail.
+	Fallback Resolver
+
+	// Servers are the addresses, as host:port pairs, of the DNS servers to
+	// query in order until one of them answers.
+	Servers []string
+
+	// Timeout is the maximum amount of time to wait for a server to answer.
+	Timeout time.Duration
+}
+
+// Compile-time check to ensure DNSResolver implements the TTLResolver
+// interface.
+var _ TTLResolver = (*DNSResolver)(nil)
+
+// NewDNSResolver returns a new DNSResolver querying the name servers listed in
+// the system's resolver configuration, falling back to [net.DefaultResolver].
+//
+// [net.DefaultResolver]: https://godocs.io/net#DefaultResolver
+func NewDNSResolver() *DNSResolver {
+	return &DNSResolver{
+		Fallback: net.DefaultResolver,
+		Servers:  systemNameServers(),
+		Timeout:  5 * time.Second,
+	}
+}
+
+// LookupIPAddr implements the Resolver interface.
+func (r *DNSResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, _, err := r.LookupIPAddrTTL(ctx, host)
+
+	return addrs, err
+}
+
+// LookupIPAddrTTL implements the TTLResolver interface, querying the A and
+// AAAA records of the host. The TTL returned is the lowest TTL of the records
+// in the answers, including CNAME records, or zero if it is unknown.
+//
+// If only one of the queries fails, the addresses returned by the other are
+// used. An error is only returned if no addresses were found and at least one
+// query failed for a reason other than the host not existing.
+func (r *DNSResolver) LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IPAddr{{IP: ip}}, 0, nil
+	}
+
+	if len(r.Servers) == 0 {
+		return r.fallback(ctx, host)
+	}
+
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, IsNotFound: true}
+	}
+
+	var (
+		types   = [...]dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+		results [len(types)]dnsAnswer
+		wg      sync.WaitGroup
+	)
+
+	for i, qtype := range types {
+		wg.Add(1)
+
+		go func(i int, qtype dnsmessage.Type) {
+			defer wg.Done()
+
+			results[i] = r.query(ctx, host, name, qtype)
+		}(i, qtype)
+	}
+
+	wg.Wait()
+
+	var (
+		addrs    []net.IPAddr
+		ttl      time.Duration
+		lastErr  error
+		notFound bool
+	)
+
+	// A failure of one address family, such as AAAA queries timing out on
+	// IPv4-only networks, must not hide the addresses of the other.
+	for _, result := range results {
+		if result.err != nil {
+			var dnsErr *net.DNSError
+			if errors.As(result.err, &dnsErr) && dnsErr.IsNotFound {
+				notFound = true
+			} else {
+				lastErr = result.err
+			}
+
+			continue
+		}
+
+		addrs = append(addrs, result.addrs...)
+
+		if len(result.addrs) > 0 && (ttl == 0 || result.ttl < ttl) {
+			ttl = result.ttl
+		}
+	}
+
+	switch {
+	case len(addrs) > 0:
+		return addrs, ttl, nil
+	case lastErr != nil && !notFound:
+		return nil, 0, lastErr
+	default:
+		return r.fallback(ctx, host)
+	}
+}
+
+// fallback looks up the host with the fallback resolver, if any.
+func (r *DNSResolver) fallback(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	if r.Fallback == nil {
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	addrs, err := r.Fallback.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w", err)
+	}
+
+	return addrs, 0, nil
+}
+
+// dnsAnswer holds the addresses returned for a single query.
+type dnsAnswer struct {
+	// err is the error of the query, if any.
+	err error
+
+	// addrs holds the addresses in the answer.
+	addrs []net.IPAddr
+
+	// ttl is the lowest TTL of the records in the answer.
+	ttl time.Duration
+}
+
+// query asks each server in turn for the records of the given type, until one
+// of them answers or reports that the host does not exist.
+func (r *DNSResolver) query(ctx context.Context, host string, name dnsmessage.Name, qtype dnsmessage.Type) dnsAnswer {
+	var answer dnsAnswer
+
+	for _, server := range r.Servers {
+		answer = r.exchange(ctx, host, server, name, qtype)
+
+		var dnsErr *net.DNSError
+		if answer.err == nil || (errors.As(answer.err, &dnsErr) && dnsErr.IsNotFound) || ctx.Err() != nil {
+			return answer
+		}
+	}
+
+	return answer
+}
+
+// exchange sends a single query to the server over UDP, retrying over TCP if
+// the response is truncated, and parses the answer.
+func (r *DNSResolver) exchange(ctx context.Context, host, server string, name dnsmessage.Name, qtype dnsmessage.Type) dnsAnswer {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	id := binary.BigEndian.Uint16(xrand.Bytes(2))
+
+	query, err := newDNSQuery(id, name, qtype)
+	if err != nil {
+		return dnsAnswer{err: &net.DNSError{Err: err.Error(), Name: host, Server: server}}
+	}
+
+	resp, err := exchangeUDP(ctx, server, id, query)
+	if err == nil && resp.header.Truncated {
+		resp, err = exchangeTCP(ctx, server, id, query)
+	}
+
+	if err != nil {
+		var netErr net.Error
+
+		return dnsAnswer{err: &net.DNSError{
+			Err:         err.Error(),
+			Name:        host,
+			Server:      server,
+			IsTimeout:   errors.As(err, &netErr) && netErr.Timeout(),
+			IsTemporary: true,
+		}}
+	}
+
+	return resp.answer(host, server, name)
+}
+
+// dnsResponse is a DNS response whose header has been parsed.
+type dnsResponse struct {
+	// parser is positioned after the header.
+	parser dnsmessage.Parser
+
+	// header is the header of the response.
+	header dnsmessage.Header
+}
+
+// answer returns the addresses and TTL in the response, following the CNAME
+// records that lead from name to them.
+func (resp *dnsResponse) answer(host, server string, name dnsmessage.Name) dnsAnswer {
+	switch resp.header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return dnsAnswer{err: &net.DNSError{Err: "no such host", Name: host, Server: server, IsNotFound: true}}
+	default:
+		return dnsAnswer{err: &net.DNSError{Err: "server misbehaving: " + resp.header.RCode.String(), Name: host, Server: server, IsTemporary: true}}
+	}
+
+	invalid := func(err error) dnsAnswer {
+		return dnsAnswer{err: &net.DNSError{Err: "invalid response: " + err.Error(), Name: host, Server: server, IsTemporary: true}}
+	}
+
+	if err := resp.parser.SkipAllQuestions(); err != nil {
+		return invalid(err)
+	}
+
+	var (
+		answer dnsAnswer
+		target = strings.ToLower(name.String())
+		ttl    = func(seconds uint32) {
+			if d := time.Duration(seconds) * time.Second; answer.ttl == 0 || d < answer.ttl {
+				answer.ttl = d
+			}
+		}
+	)
+
+	for {
+		header, err := resp.parser.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+
+		if err != nil {
+			return invalid(err)
+		}
+
+		if header.Class != dnsmessage.ClassINET || strings.ToLower(header.Name.String()) != target {
+			if err = resp.parser.SkipAnswer(); err != nil {
+				return invalid(err)
+			}
+
+			continue
+		}
+
+		switch header.Type {
+		case dnsmessage.TypeA:
+			res, err := resp.parser.AResource()
+			if err != nil {
+				return invalid(err)
+			}
+
+			answer.addrs = append(answer.addrs, net.IPAddr{IP: net.IP(res.A[:])})
+			ttl(header.TTL)
+		case dnsmessage.TypeAAAA:
+			res, err := resp.parser.AAAAResource()
+			if err != nil {
+				return invalid(err)
+			}
+
+			answer.addrs = append(answer.addrs, net.IPAddr{IP: net.IP(res.AAAA[:])})
+			ttl(header.TTL)
+		case dnsmessage.TypeCNAME:
+			res, err := resp.parser.CNAMEResource()
+			if err != nil {
+				return invalid(err)
+			}
+
+			target = strings.ToLower(res.CNAME.String())
+			ttl(header.TTL)
+		default:
+			if err = resp.parser.SkipAnswer(); err != nil {
+				return invalid(err)
+			}
+		}
+	}
+
+	if len(answer.addrs) == 0 {
+		answer.ttl = 0
+	}
+
+	return answer
+}
+
+// newDNSQuery returns a recursive query for the records of the given type.
+func newDNSQuery(id uint16, name dnsmessage.Name, qtype dnsmessage.Type) ([]byte, error) {
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	builder.EnableCompression()
+
+	if err := builder.StartQuestions(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	err := builder.Question(dnsmessage.Question{
+		Name:  name,
+		Type:  qtype,
+		Class: dnsmessage.ClassINET,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	query, err := builder.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return query, nil
+}
+
+// exchangeUDP sends the query to the server over UDP and returns the first
+// response with the same ID.
+func exchangeUDP(ctx context.Context, server string, id uint16, query []byte) (*dnsResponse, error) {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	if _, err = conn.Write(query); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	buf := make([]byte, _dnsUDPSize)
+
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		// Responses with another ID are stale or spoofed, so keep waiting.
+		if resp, err := parseDNSResponse(buf[:n], id); err == nil {
+			return resp, nil
+		}
+	}
+}
+
+// exchangeTCP sends the query to the server over TCP and returns its response.
+func exchangeTCP(ctx context.Context, server string, id uint16, query []byte) (*dnsResponse, error) {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+
+	if _, err = conn.Write(msg); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	var length [2]byte
+
+	if _, err = io.ReadFull(conn, length[:]); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return parseDNSResponse(buf, id)
+}
+
+// parseDNSResponse parses the header of a response to the query with the
+// given ID.
+func parseDNSResponse(msg []byte, id uint16) (*dnsResponse, error) {
+	resp := &dnsResponse{}
+
+	header, err := resp.parser.Start(msg)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if !header.Response || header.ID != id {
+		return nil, &net.DNSError{Err: "unexpected response ID", IsTemporary: true}
+	}
+
+	resp.header = header
+
+	return resp, nil
+}
+
+// systemNameServers returns the addresses of the name servers listed in the
+// system's resolver configuration, or nil if there is none.
+func systemNameServers() []string {
+	file, err := os.Open(_resolvConf)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var (
+		servers []string
+		scanner = bufio.NewScanner(file)
+	)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+
+		if ip := net.ParseIP(strings.SplitN(fields[1], "%", 2)[0]); ip != nil {
+			servers = append(servers, net.JoinHostPort(fields[1], _dnsPort))
+		}
+	}
+
+	return servers
+}
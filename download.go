@@ -0,0 +1,534 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrRangeNotSupported is returned when a server ignores the Range header of a
+// request resuming a download.
+const ErrRangeNotSupported xerrors.Error = "server does not support range requests"
+
+// ErrResourceChanged is returned when the resource being downloaded changes
+// before the download finishes.
+const ErrResourceChanged xerrors.Error = "resource changed during download"
+
+// ErrInvalidContentRange is returned when the Content-Range of a partial
+// response does not match the range that was requested.
+const ErrInvalidContentRange xerrors.Error = "invalid Content-Range"
+
+const (
+	// _defaultChunkSize is the default size of chunks downloaded in parallel.
+	_defaultChunkSize int64 = 8 << 20
+
+	// _defaultMaxResumes is the default number of times a download range is
+	// resumed after being interrupted.
+	_defaultMaxResumes int = 5
+)
+
+// Downloader downloads resources using Range requests, resuming transfers
+// interrupted by transport errors instead of restarting them from the start.
+//
+// Resumed requests carry an If-Range header with the resource's ETag or
+// Last-Modified date, and their Content-Range and ETag are checked against the
+// original response, so a download never mixes two versions of a resource.
+// Resources without a strong ETag or Last-Modified date cannot be resumed.
+//
+// The client's timeout and cache do not apply to downloads; use the context to
+// limit how long a download takes.
+type Downloader struct {
+	// client is the client used to send requests.
+	client *Client
+
+	// ChunkSize is the size of the byte ranges downloaded in parallel when
+	// Concurrency is greater than one.
+	ChunkSize int64
+
+	// Concurrency is the number of byte ranges downloaded in parallel. If it
+	// is one or less, or the server does not support range requests, the
+	// resource is downloaded sequentially.
+	Concurrency int
+
+	// MaxResumes is the maximum number of times each byte range is resumed
+	// after being interrupted.
+	MaxResumes int
+}
+
+// NewDownloader returns a new Downloader sending requests through the given
+// client. Downloads are sequential by default.
+func NewDownloader(client *Client) *Downloader {
+	return &Downloader{
+		client:      client,
+		ChunkSize:   _defaultChunkSize,
+		Concurrency: 1,
+		MaxResumes:  _defaultMaxResumes,
+	}
+}
+
+// Download is a convenience method for downloading a resource to w with a
+// default Downloader.
+func (c *Client) Download(ctx context.Context, uri string, w io.WriterAt) (int64, error) {
+	return NewDownloader(c).Download(ctx, uri, w)
+}
+
+// Download downloads the resource at uri to w and returns the number of bytes
+// written.
+func (d *Downloader) Download(ctx context.Context, uri string, w io.WriterAt) (int64, error) {
+	var (
+		dl = &download{
+			client:     d.client,
+			uri:        uri,
+			w:          w,
+			size:       -1,
+			maxResumes: d.MaxResumes,
+		}
+		parallel = d.Concurrency > 1 && d.ChunkSize > 0
+		end      = int64(-1)
+	)
+
+	ctx = WithStreaming(ctx)
+
+	if parallel {
+		end = d.ChunkSize - 1
+	}
+
+	resp, err := dl.open(ctx, end)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return dl.transfer(ctx, resp, 0, -1)
+	}
+
+	if dl.size == 0 {
+		dl.client.discardResponse(resp)
+
+		return 0, nil
+	}
+
+	if dl.size < 0 {
+		n, err := dl.transfer(ctx, resp, 0, end)
+		if err != nil {
+			return n, err
+		}
+
+		rest, err := dl.fetch(ctx, end+1, -1)
+
+		return n + rest, err
+	}
+
+	return dl.parallel(ctx, resp, d.ChunkSize, d.Concurrency)
+}
+
+// DownloadFile downloads the resource at uri to the named file, creating or
+// truncating it, and returns the number of bytes written. If the download
+// fails, the file is left partially written.
+func (d *Downloader) DownloadFile(ctx context.Context, uri, name string) (n int64, err error) {
+	file, err := os.Create(name)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("%w", closeErr)
+		}
+	}()
+
+	return d.Download(ctx, uri, file)
+}
+
+// download holds the state of a single download.
+type download struct {
+	// client is the client used to send requests.
+	client *Client
+
+	// w is where the resource is written to.
+	w io.WriterAt
+
+	// uri is the URI of the resource.
+	uri string
+
+	// etag is the ETag of the resource, if any.
+	etag string
+
+	// validator is the value sent in the If-Range header when resuming, or
+	// empty if the download cannot be resumed.
+	validator string
+
+	// size is the size of the resource, or -1 if unknown.
+	size int64
+
+	// maxResumes is the maximum number of times a range is resumed.
+	maxResumes int
+}
+
+// open sends the first request of the download, for the byte range from zero
+// to end if end is not negative, and records the resource's size and
+// validators from its response.
+func (dl *download) open(ctx context.Context, end int64) (*http.Response, error) {
+	var byteRange string
+
+	if end >= 0 {
+		byteRange = formatByteRange(0, end)
+	}
+
+	resp, err := dl.send(ctx, byteRange, "")
+	if err != nil {
+		return nil, err
+	}
+
+	size := resp.ContentLength
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && resp.Header.Get("Content-Range") == "bytes */0":
+		// No range of an empty resource can be satisfied, so servers reject
+		// the first range of a parallel download.
+		size = 0
+	default:
+		size, err = dl.check(resp, 0, end)
+		if err != nil {
+			dl.client.discardResponse(resp)
+
+			return nil, err
+		}
+	}
+
+	dl.size = size
+	dl.etag = resp.Header.Get("ETag")
+
+	if !strings.EqualFold(resp.Header.Get("Accept-Ranges"), "none") {
+		dl.validator = rangeValidator(resp)
+	}
+
+	return resp, nil
+}
+
+// fetch downloads the byte range from start to end, or to the end of the
+// resource if end is negative.
+func (dl *download) fetch(ctx context.Context, start, end int64) (int64, error) {
+	resp, err := dl.get(ctx, start, end)
+	if err != nil {
+		return 0, err
+	}
+
+	return dl.transfer(ctx, resp, start, end)
+}
+
+// get sends a request for the byte range from start to end and checks that
+// the response matches it.
+func (dl *download) get(ctx context.Context, start, end int64) (*http.Response, error) {
+	resp, err := dl.send(ctx, formatByteRange(start, end), dl.validator)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = dl.check(resp, start, end); err != nil {
+		dl.client.discardResponse(resp)
+
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// send sends a GET request for the resource with the given Range and If-Range
+// headers, if not empty.
+func (dl *download) send(ctx context.Context, byteRange, ifRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dl.uri, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	// Byte ranges refer to the encoded content, so transparent decompression
+	// would break resumed downloads.
+	req.Header.Set("Accept-Encoding", "identity")
+
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+
+	if ifRange != "" {
+		req.Header.Set("If-Range", ifRange)
+	}
+
+	resp, err := dl.client.Do(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return resp, nil
+}
+
+// check checks that a response to a request for the byte range from start to
+// end is a partial response for that range of the same resource, and returns
+// the size of the resource given by its Content-Range, or -1 if unknown.
+func (dl *download) check(resp *http.Response, start, end int64) (int64, error) {
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		if dl.validator == "" || rangeValidator(resp) == dl.validator {
+			return 0, ErrRangeNotSupported
+		}
+
+		return 0, ErrResourceChanged
+	default:
+		return 0, &Error{
+			URL:        resp.Request.URL,
+			Method:     resp.Request.Method,
+			Message:    "unexpected status for download",
+			StatusText: http.StatusText(resp.StatusCode),
+			StatusCode: resp.StatusCode,
+		}
+	}
+
+	contentRange := resp.Header.Get("Content-Range")
+
+	first, last, size, err := parseContentRange(contentRange)
+	if err != nil {
+		return 0, err
+	}
+
+	want := end
+	if size >= 0 && (want < 0 || want >= size) {
+		want = size - 1
+	}
+
+	if first != start || (want >= 0 && last != want) || (dl.size >= 0 && size >= 0 && size != dl.size) {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidContentRange, contentRange)
+	}
+
+	if etag := resp.Header.Get("ETag"); dl.etag != "" && etag != "" && etag != dl.etag {
+		return 0, ErrResourceChanged
+	}
+
+	return size, nil
+}
+
+// transfer writes the body of a response for the byte range from start to end
+// to the destination, resuming the transfer with new requests if it is
+// interrupted.
+func (dl *download) transfer(ctx context.Context, resp *http.Response, start, end int64) (int64, error) {
+	offset := start
+
+	for resumes := 0; ; resumes++ {
+		n, resumable, err := dl.copy(resp, offset)
+		offset += n
+
+		if err == nil {
+			if err = dl.complete(offset, end); err == nil {
+				return offset - start, nil
+			}
+		}
+
+		if !resumable || dl.validator == "" || resumes >= dl.maxResumes || ctx.Err() != nil {
+			return offset - start, err
+		}
+
+		dl.client.debugf("[DEBUG] Resuming download at byte %d: GET %s", offset, dl.uri)
+
+		if dl.client.RetryPolicy != nil {
+			if err = dl.client.RetryPolicy.sleep(ctx, dl.client.RetryPolicy.RetryAfter(nil)); err != nil {
+				return offset - start, err
+			}
+		}
+
+		if resp, err = dl.get(ctx, offset, end); err != nil {
+			return offset - start, err
+		}
+	}
+}
+
+// copy writes the body of the response to the destination at the given offset
+// and closes it. It reports whether an error came from reading the body, in
+// which case the transfer can be resumed.
+func (dl *download) copy(resp *http.Response, offset int64) (int64, bool, error) {
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			dl.client.debugf("[DEBUG] Failed to close download response: %v", err)
+		}
+	}()
+
+	writer := &downloadWriter{w: io.NewOffsetWriter(dl.w, offset)}
+
+	n, err := io.Copy(writer, resp.Body)
+	if err != nil {
+		return n, writer.err == nil, fmt.Errorf("%w", err)
+	}
+
+	return n, true, nil
+}
+
+// complete checks that the transfer of the byte range ending at end, or at the
+// end of the resource if end is negative, stopped at the end of the range.
+func (dl *download) complete(offset, end int64) error {
+	want := dl.size
+	if end >= 0 && (want < 0 || end < want) {
+		want = end + 1
+	}
+
+	if want >= 0 && offset < want {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+// parallel downloads the resource in byte ranges of the given size, using the
+// response for the first range, with up to concurrency ranges in flight.
+func (dl *download) parallel(ctx context.Context, first *http.Response, chunkSize int64, concurrency int) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		starts  = make(chan int64)
+		written int64
+		failed  error
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+	)
+
+	done := func(n int64, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		written += n
+
+		if err != nil && failed == nil {
+			failed = err
+
+			cancel()
+		}
+	}
+
+	worker := func() {
+		defer wg.Done()
+
+		for start := range starts {
+			end := start + chunkSize - 1
+			if end >= dl.size {
+				end = dl.size - 1
+			}
+
+			done(dl.fetch(ctx, start, end))
+		}
+	}
+
+	wg.Add(concurrency)
+
+	go func() {
+		done(dl.transfer(ctx, first, 0, chunkSize-1))
+
+		worker()
+	}()
+
+	for i := 1; i < concurrency; i++ {
+		go worker()
+	}
+
+feed:
+	for start := chunkSize; start < dl.size; start += chunkSize {
+		select {
+		case starts <- start:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(starts)
+	wg.Wait()
+
+	return written, failed
+}
+
+// downloadWriter is an io.Writer that records the errors of the underlying
+// writer, telling them apart from errors reading what is written.
+type downloadWriter struct {
+	// w is the underlying writer.
+	w io.Writer
+
+	// err is the first error returned by the underlying writer.
+	err error
+}
+
+// Write writes to the underlying writer and records its error.
+func (w *downloadWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil {
+		w.err = fmt.Errorf("%w", err)
+
+		return n, w.err
+	}
+
+	return n, nil
+}
+
+// formatByteRange returns a Range header for the bytes from start to end, or
+// to the end of the resource if end is negative.
+func formatByteRange(start, end int64) string {
+	if end < 0 {
+		return "bytes=" + strconv.FormatInt(start, 10) + "-"
+	}
+
+	return "bytes=" + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(end, 10)
+}
+
+// parseContentRange parses a Content-Range header, returning the first and
+// last byte of the range and the size of the resource, or -1 if unknown.
+func parseContentRange(contentRange string) (first, last, size int64, err error) {
+	invalid := fmt.Errorf("%w: %s", ErrInvalidContentRange, contentRange)
+
+	unit, value, ok := strings.Cut(contentRange, " ")
+	if !ok || unit != "bytes" {
+		return 0, 0, 0, invalid
+	}
+
+	byteRange, sizeValue, ok := strings.Cut(value, "/")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+
+	firstValue, lastValue, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+
+	if first, err = strconv.ParseInt(firstValue, 10, 64); err != nil {
+		return 0, 0, 0, invalid
+	}
+
+	if last, err = strconv.ParseInt(lastValue, 10, 64); err != nil || last < first {
+		return 0, 0, 0, invalid
+	}
+
+	if sizeValue == "*" {
+		return first, last, -1, nil
+	}
+
+	if size, err = strconv.ParseInt(sizeValue, 10, 64); err != nil || size <= last {
+		return 0, 0, 0, invalid
+	}
+
+	return first, last, size, nil
+}
+
+// rangeValidator returns the validator sent in the If-Range header of requests
+// resuming the download of the response's resource, which is its ETag if
+// strong, or its Last-Modified date.
+func rangeValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return resp.Header.Get("Last-Modified")
+}
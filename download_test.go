@@ -0,0 +1,225 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+// abortWriter is an http.ResponseWriter that aborts the response after writing
+// a number of body bytes.
+type abortWriter struct {
+	http.ResponseWriter
+	remaining int
+}
+
+func (w *abortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.remaining {
+		p = p[:w.remaining]
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+
+	w.remaining -= n
+	if w.remaining == 0 {
+		w.ResponseWriter.(http.Flusher).Flush()
+
+		panic(http.ErrAbortHandler)
+	}
+
+	return n, err
+}
+
+func TestDownloader_DownloadFile(t *testing.T) {
+	t.Parallel()
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64)
+
+	tests := []struct {
+		name        string
+		concurrency int
+		abortAfter  int
+		ignoreRange bool
+		changeETag  bool
+		empty       bool
+		wantRanges  []string
+		wantErr     error
+	}{
+		{
+			name:       "sequential download is resumed",
+			abortAfter: 300,
+			wantRanges: []string{"", "bytes=300-"},
+		},
+		{
+			name:        "parallel download",
+			concurrency: 3,
+			wantRanges: []string{
+				"bytes=0-255", "bytes=256-511", "bytes=512-767", "bytes=768-1023",
+			},
+		},
+		{
+			name:        "parallel chunk is resumed",
+			concurrency: 3,
+			abortAfter:  100,
+			wantRanges: []string{
+				"bytes=0-255", "bytes=100-255", "bytes=256-511", "bytes=512-767", "bytes=768-1023",
+			},
+		},
+		{
+			name:        "parallel download falls back to sequential",
+			concurrency: 3,
+			ignoreRange: true,
+			wantRanges:  []string{"bytes=0-255"},
+		},
+		{
+			name:       "empty resource",
+			empty:      true,
+			wantRanges: []string{""},
+		},
+		{
+			name:        "empty resource in parallel",
+			concurrency: 3,
+			empty:       true,
+			wantRanges:  []string{"bytes=0-255"},
+		},
+		{
+			name:       "resource changed",
+			abortAfter: 300,
+			changeETag: true,
+			wantRanges: []string{"", "bytes=300-"},
+			wantErr:    httpx.ErrResourceChanged,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu     sync.Mutex
+				ranges []string
+				want   = content
+			)
+
+			if tt.empty {
+				want = nil
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				ranges = append(ranges, r.Header.Get("Range"))
+				first := len(ranges) == 1
+				mu.Unlock()
+
+				if tt.ignoreRange {
+					w.Write(content)
+
+					return
+				}
+
+				// Like many servers, reject any range of an empty resource.
+				if tt.empty {
+					if r.Header.Get("Range") != "" {
+						w.Header().Set("Content-Range", "bytes */0")
+						w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+					}
+
+					return
+				}
+
+				w.Header().Set("ETag", `"v1"`)
+
+				if tt.changeETag && !first {
+					w.Header().Set("ETag", `"v2"`)
+				}
+
+				if first && tt.abortAfter > 0 {
+					w = &abortWriter{ResponseWriter: w, remaining: tt.abortAfter}
+				}
+
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			}))
+			t.Cleanup(server.Close)
+
+			client := httpx.NewClient()
+			client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+			client.RetryPolicy.MinRetryDelay = time.Millisecond
+			client.RetryPolicy.MaxRetryDelay = time.Millisecond
+
+			downloader := httpx.NewDownloader(client)
+			downloader.ChunkSize = 256
+
+			if tt.concurrency > 0 {
+				downloader.Concurrency = tt.concurrency
+			}
+
+			name := filepath.Join(t.TempDir(), "download")
+
+			n, err := downloader.DownloadFile(context.Background(), server.URL, name)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if n != int64(len(want)) {
+					t.Errorf("got %d bytes, want %d", n, len(want))
+				}
+
+				got, err := os.ReadFile(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(got, want) {
+					t.Errorf("downloaded content does not match")
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if !sameElements(ranges, tt.wantRanges) {
+				t.Errorf("got ranges %q, want %q", ranges, tt.wantRanges)
+			}
+		})
+	}
+}
+
+// sameElements reports whether a and b hold the same elements, in any order.
+func sameElements(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	counts := make(map[string]int, len(a))
+
+	for _, s := range a {
+		counts[s]++
+	}
+
+	for _, s := range b {
+		counts[s]--
+
+		if counts[s] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -3,6 +3,7 @@ package httpx
 import (
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -66,3 +67,43 @@ type RetryAfterExceededError struct {
 func (e *RetryAfterExceededError) Error() string {
 	return fmt.Sprintf("retry limit exceeded: max retries %d, retry after %s", e.MaxRetries, e.RetryAfter)
 }
+
+// CircuitOpenError represents an error that occurs when a request is rejected
+// without being sent because the circuit breaker for its host is open.
+type CircuitOpenError struct {
+	// RetryAt is the earliest time at which the circuit breaker will allow a
+	// trial request to the host.
+	RetryAt time.Time
+
+	// Host is the host whose circuit is open.
+	Host string
+}
+
+// Error returns a human-readable error message describing the circuit open
+// error. It implements the error interface.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open: host %s, retry at %s", e.Host, e.RetryAt.Format(time.RFC1123))
+}
+
+// UnexpectedContentTypeError represents an error that occurs when a response
+// body cannot be decoded because its Content-Type is missing or has no codec
+// registered, such as an HTML error page returned by a proxy.
+type UnexpectedContentTypeError struct {
+	// URL is the URL that was requested, if known.
+	URL *url.URL
+
+	// ContentType is the Content-Type of the response, empty if missing.
+	ContentType string
+
+	// Expected lists the media types that could have been decoded.
+	Expected []string
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+}
+
+// Error returns a human-readable error message describing the unexpected
+// content type error. It implements the error interface.
+func (e *UnexpectedContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected content type %q with status %d (%s): expected one of %s", e.ContentType, e.StatusCode, e.URL, strings.Join(e.Expected, ", "))
+}
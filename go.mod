@@ -5,7 +5,26 @@ go 1.20
 require (
 	git.sr.ht/~jamesponddotco/pagecache-go v0.0.0-20230411150210-54b704d32088
 	git.sr.ht/~jamesponddotco/xstd-go v0.0.0-20230409194931-7d4d783b26b2
+	github.com/fxamacker/cbor/v2 v2.9.2
+	github.com/prometheus/client_golang v1.15.0
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/net v0.11.0
+	golang.org/x/sync v0.10.0
 	golang.org/x/time v0.3.0
+	google.golang.org/protobuf v1.30.0
 )
 
-require git.sr.ht/~jamesponddotco/recache-go v1.0.1 // indirect
+require (
+	git.sr.ht/~jamesponddotco/recache-go v1.0.1 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.4 // indirect
+	github.com/prometheus/client_model v0.3.0 // indirect
+	github.com/prometheus/common v0.42.0 // indirect
+	github.com/prometheus/procfs v0.9.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/sys v0.9.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+)
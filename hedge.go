@@ -0,0 +1,207 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrHedgeFailed is returned when every attempt of a hedged request fails. It
+// wraps the error of the last attempt to fail.
+const ErrHedgeFailed xerrors.Error = "all hedged attempts failed"
+
+// HedgePolicy defines a policy for hedging requests, sending additional
+// identical attempts when a response takes too long to arrive and using
+// whichever finishes first.
+//
+// Hedging only applies to requests with idempotent methods whose body, if any,
+// can be replayed and read by several attempts at once, which excludes bodies
+// built by MultipartBody.
+type HedgePolicy struct {
+	// Delay is how long to wait for a response before sending another
+	// attempt.
+	Delay time.Duration
+
+	// MaxHedges is the maximum number of additional attempts sent for a
+	// single request.
+	MaxHedges int
+}
+
+// DefaultHedgePolicy returns a HedgePolicy with sensible defaults, sending a
+// single hedged attempt after 100 milliseconds.
+func DefaultHedgePolicy() *HedgePolicy {
+	return &HedgePolicy{
+		Delay:     100 * time.Millisecond,
+		MaxHedges: 1,
+	}
+}
+
+// HedgeAttempt returns which attempt of a hedged request produced the given
+// response, with zero being the original attempt. It returns zero for
+// responses to requests that were not hedged.
+func HedgeAttempt(resp *http.Response) int {
+	if resp == nil || resp.Request == nil {
+		return 0
+	}
+
+	attempt, ok := resp.Request.Context().Value(hedgeAttemptKey{}).(int)
+	if !ok {
+		return 0
+	}
+
+	return attempt
+}
+
+// hedgeAttemptKey is the context key used to store the hedge attempt number.
+type hedgeAttemptKey struct{}
+
+// hedgeResult holds the outcome of a single hedged attempt.
+type hedgeResult struct {
+	resp    *http.Response
+	err     error
+	attempt int
+}
+
+// applies reports whether the policy applies to the given request.
+func (p *HedgePolicy) applies(req *http.Request) bool {
+	return p.MaxHedges > 0 && isIdempotent(req.Method) && canRewindBody(req) && !hasSequentialBody(req)
+}
+
+// send sends a single attempt of the request, hedging it if the client has a
+// hedge policy that applies to the request.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.HedgePolicy == nil || !c.HedgePolicy.applies(req) {
+		resp, err := c.httpClient(req).Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		return resp, nil
+	}
+
+	return c.hedge(req)
+}
+
+// hedge sends the request, launching another attempt every time the policy's
+// delay passes without a response, and returns the first response received.
+// All other attempts are canceled.
+func (c *Client) hedge(req *http.Request) (*http.Response, error) {
+	var (
+		ctx      = req.Context()
+		attempts = 1 + c.HedgePolicy.MaxHedges
+		results  = make(chan hedgeResult, attempts)
+		cancels  = make([]context.CancelFunc, 0, attempts)
+		timer    = time.NewTimer(c.HedgePolicy.Delay)
+		pending  int
+		lastErr  error
+	)
+
+	defer timer.Stop()
+
+	launch := func(attempt int) {
+		attemptCtx, cancel := context.WithCancel(context.WithValue(ctx, hedgeAttemptKey{}, attempt))
+		cancels = append(cancels, cancel)
+		pending++
+
+		go func() {
+			resp, err := c.hedgeAttempt(attemptCtx, req, attempt)
+
+			results <- hedgeResult{resp: resp, err: err, attempt: attempt}
+		}()
+	}
+
+	launch(0)
+
+	for pending > 0 {
+		select {
+		case result := <-results:
+			pending--
+
+			if result.err != nil {
+				lastErr = result.err
+
+				continue
+			}
+
+			for i, cancel := range cancels {
+				if i != result.attempt {
+					cancel()
+				}
+			}
+
+			go c.discardHedges(results, pending)
+
+			result.resp.Body = &cancelBody{
+				ReadCloser: result.resp.Body,
+				cancel:     cancels[result.attempt],
+			}
+
+			return result.resp, nil
+		case <-timer.C:
+			if len(cancels) < attempts {
+				c.debugf("[DEBUG] Hedging request: %s %s", req.Method, req.URL)
+
+				launch(len(cancels))
+				timer.Reset(c.HedgePolicy.Delay)
+			}
+		}
+	}
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+
+	return nil, fmt.Errorf("%w: %w", ErrHedgeFailed, lastErr)
+}
+
+// hedgeAttempt sends a copy of the request bound to the given context. Hedged
+// attempts wait on the client's rate limiter before being sent.
+func (c *Client) hedgeAttempt(ctx context.Context, req *http.Request, attempt int) (*http.Response, error) {
+	clone := req.Clone(ctx)
+
+	if err := rewindBody(clone); err != nil {
+		return nil, err
+	}
+
+	if err := c.applyRateLimiter(attempt, clone); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient(clone).Do(clone)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return resp, nil
+}
+
+// discardHedges waits for the remaining hedged attempts to finish and discards
+// their responses.
+func (c *Client) discardHedges(results <-chan hedgeResult, pending int) {
+	for i := 0; i < pending; i++ {
+		result := <-results
+
+		c.discardResponse(result.resp)
+	}
+}
+
+// cancelBody is an io.ReadCloser that cancels a context once closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the underlying body and cancels the context.
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+
+	if err := b.ReadCloser.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
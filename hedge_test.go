@@ -0,0 +1,108 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+func TestClient_HedgePolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		method      string
+		wantAttempt int
+		wantCalls   int32
+	}{
+		{
+			name:        "slow GET is hedged",
+			method:      http.MethodGet,
+			wantAttempt: 1,
+			wantCalls:   2,
+		},
+		{
+			name:        "slow POST is not hedged",
+			method:      http.MethodPost,
+			wantAttempt: 0,
+			wantCalls:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(500 * time.Millisecond):
+					}
+				}
+
+				w.WriteHeader(http.StatusOK)
+			}))
+			t.Cleanup(server.Close)
+
+			client := httpx.NewClient()
+			client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+			client.HedgePolicy = &httpx.HedgePolicy{
+				Delay:     20 * time.Millisecond,
+				MaxHedges: 1,
+			}
+
+			req, err := http.NewRequestWithContext(context.Background(), tt.method, server.URL, http.NoBody)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.Do(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := httpx.HedgeAttempt(resp); got != tt.wantAttempt {
+				t.Errorf("HedgeAttempt() = %d, want %d", got, tt.wantAttempt)
+			}
+
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("server received %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestClient_HedgePolicyError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy = nil
+	client.HedgePolicy = httpx.DefaultHedgePolicy()
+
+	resp, err := client.Get(context.Background(), server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected error, got nil")
+	}
+
+	if !errors.Is(err, httpx.ErrHedgeFailed) {
+		t.Errorf("got error %v, want %v", err, httpx.ErrHedgeFailed)
+	}
+}
@@ -0,0 +1,362 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrInvalidLinkHeader is returned when a Link header cannot be parsed.
+const ErrInvalidLinkHeader xerrors.Error = "invalid Link header"
+
+// Link is a web link parsed from a Link header, as defined in RFC 8288.
+type Link struct {
+	// URL is the target of the link, resolved against the URL of the request
+	// if it was relative.
+	URL *url.URL
+
+	// Params holds the target attributes other than rel, type and title, such
+	// as anchor, hreflang, media and extension attributes, keyed by their
+	// lowercased name. Only the first occurrence of each attribute is kept,
+	// and attributes without a value are empty.
+	Params map[string]string
+
+	// Type is the media type hint of the target, if any.
+	Type string
+
+	// Title is the title of the link, taken from the title* attribute if
+	// present and valid, or from the title attribute otherwise.
+	Title string
+
+	// Rel holds the relation types of the link. Registered relation types
+	// are lowercased, while extension relation types, which are URIs, are kept
+	// as is.
+	Rel []string
+}
+
+// HasRel reports whether the link has the given relation type. Registered
+// relation types are compared case-insensitively.
+func (l Link) HasRel(rel string) bool {
+	for _, r := range l.Rel {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Links parses every Link header of the response, resolving relative targets
+// against the URL of the request that produced it.
+func Links(resp *http.Response) ([]Link, error) {
+	var base *url.URL
+
+	if resp.Request != nil {
+		base = resp.Request.URL
+	}
+
+	var links []Link
+
+	for _, header := range resp.Header.Values("Link") {
+		parsed, err := ParseLinkHeader(header, base)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, parsed...)
+	}
+
+	return links, nil
+}
+
+// FindLink returns the first link with the given relation type.
+func FindLink(links []Link, rel string) (Link, bool) {
+	for _, link := range links {
+		if link.HasRel(rel) {
+			return link, true
+		}
+	}
+
+	return Link{}, false
+}
+
+// ParseLinkHeader parses the value of a Link header, as defined in RFC 8288,
+// resolving relative targets against base if it is not nil.
+func ParseLinkHeader(header string, base *url.URL) ([]Link, error) {
+	var (
+		parser = &linkParser{s: header}
+		links  []Link
+	)
+
+	for {
+		parser.skipSpace()
+
+		if parser.done() {
+			return links, nil
+		}
+
+		// Empty list elements are allowed by the list syntax of RFC 9110.
+		if parser.peek() == ',' {
+			parser.pos++
+
+			continue
+		}
+
+		link, err := parser.link(base)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, link)
+
+		parser.skipSpace()
+
+		if parser.done() {
+			return links, nil
+		}
+
+		if parser.peek() != ',' {
+			return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrInvalidLinkHeader, parser.peek(), parser.pos)
+		}
+
+		parser.pos++
+	}
+}
+
+// linkParser is a scanner over the value of a Link header.
+type linkParser struct {
+	// s is the header value.
+	s string
+
+	// pos is the position of the next byte to read.
+	pos int
+}
+
+// link parses a single link-value.
+func (p *linkParser) link(base *url.URL) (Link, error) {
+	if p.peek() != '<' {
+		return Link{}, fmt.Errorf("%w: expected '<' at position %d", ErrInvalidLinkHeader, p.pos)
+	}
+
+	end := strings.IndexByte(p.s[p.pos:], '>')
+	if end < 0 {
+		return Link{}, fmt.Errorf("%w: unterminated target at position %d", ErrInvalidLinkHeader, p.pos)
+	}
+
+	target := p.s[p.pos+1 : p.pos+end]
+	p.pos += end + 1
+
+	var (
+		u   *url.URL
+		err error
+	)
+
+	if base != nil {
+		u, err = base.Parse(target)
+	} else {
+		u, err = url.Parse(target)
+	}
+
+	if err != nil {
+		return Link{}, fmt.Errorf("%w: %w", ErrInvalidLinkHeader, err)
+	}
+
+	params, err := p.params()
+	if err != nil {
+		return Link{}, err
+	}
+
+	link := Link{
+		URL:    u,
+		Params: params,
+		Rel:    parseRel(params["rel"]),
+		Type:   params["type"],
+		Title:  params["title"],
+	}
+
+	if title, ok := decodeExtValue(params["title*"]); ok {
+		link.Title = title
+	}
+
+	for _, name := range [...]string{"rel", "type", "title", "title*"} {
+		delete(link.Params, name)
+	}
+
+	return link, nil
+}
+
+// params parses the link-params following a target, keeping the first
+// occurrence of each.
+func (p *linkParser) params() (map[string]string, error) {
+	params := make(map[string]string)
+
+	for {
+		p.skipSpace()
+
+		if p.done() || p.peek() == ',' {
+			return params, nil
+		}
+
+		if p.peek() != ';' {
+			return nil, fmt.Errorf("%w: expected ';' at position %d", ErrInvalidLinkHeader, p.pos)
+		}
+
+		p.pos++
+		p.skipSpace()
+
+		name := strings.ToLower(p.token())
+		if name == "" {
+			// Tolerate empty parameters, such as a trailing semicolon.
+			continue
+		}
+
+		var value string
+
+		p.skipSpace()
+
+		if !p.done() && p.peek() == '=' {
+			p.pos++
+			p.skipSpace()
+
+			var err error
+
+			if value, err = p.value(); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, ok := params[name]; !ok {
+			params[name] = value
+		}
+	}
+}
+
+// token reads an RFC 9110 token.
+func (p *linkParser) token() string {
+	start := p.pos
+
+	for !p.done() && isTokenChar(p.peek()) {
+		p.pos++
+	}
+
+	return p.s[start:p.pos]
+}
+
+// value reads a parameter value, either a quoted string or, leniently, any
+// run of characters up to the next delimiter.
+func (p *linkParser) value() (string, error) {
+	if p.done() || p.peek() != '"' {
+		start := p.pos
+
+		for !p.done() && !strings.ContainsRune(";, \t", rune(p.peek())) {
+			p.pos++
+		}
+
+		return p.s[start:p.pos], nil
+	}
+
+	var (
+		value strings.Builder
+		start = p.pos
+	)
+
+	for p.pos++; !p.done(); p.pos++ {
+		switch c := p.peek(); c {
+		case '"':
+			p.pos++
+
+			return value.String(), nil
+		case '\\':
+			p.pos++
+
+			if p.done() {
+				return "", fmt.Errorf("%w: unterminated quoted string at position %d", ErrInvalidLinkHeader, start)
+			}
+
+			value.WriteByte(p.peek())
+		default:
+			value.WriteByte(c)
+		}
+	}
+
+	return "", fmt.Errorf("%w: unterminated quoted string at position %d", ErrInvalidLinkHeader, start)
+}
+
+// skipSpace skips optional whitespace.
+func (p *linkParser) skipSpace() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// peek returns the next byte without consuming it.
+func (p *linkParser) peek() byte {
+	return p.s[p.pos]
+}
+
+// done reports whether the whole header was read.
+func (p *linkParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+// parseRel splits the value of a rel attribute into relation types,
+// lowercasing registered relation types.
+func parseRel(value string) []string {
+	rels := strings.Fields(value)
+
+	for i, rel := range rels {
+		if !strings.Contains(rel, ":") {
+			rels[i] = strings.ToLower(rel)
+		}
+	}
+
+	return rels
+}
+
+// isTokenChar reports whether c is allowed in an RFC 9110 token.
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	default:
+		return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+	}
+}
+
+// decodeExtValue decodes an RFC 8187 ext-value, such as UTF-8'en'%E2%82%AC,
+// and reports whether it was valid.
+func decodeExtValue(value string) (string, bool) {
+	charset, rest, ok := strings.Cut(value, "'")
+	if !ok {
+		return "", false
+	}
+
+	_, encoded, ok := strings.Cut(rest, "'")
+	if !ok {
+		return "", false
+	}
+
+	decoded, err := url.PathUnescape(encoded)
+	if err != nil {
+		return "", false
+	}
+
+	switch strings.ToLower(charset) {
+	case "utf-8":
+		return decoded, utf8.ValidString(decoded)
+	case "iso-8859-1":
+		runes := make([]rune, 0, len(decoded))
+
+		for i := 0; i < len(decoded); i++ {
+			runes = append(runes, rune(decoded[i]))
+		}
+
+		return string(runes), true
+	default:
+		return "", false
+	}
+}
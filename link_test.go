@@ -0,0 +1,154 @@
+package httpx_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+)
+
+func TestParseLinkHeader(t *testing.T) {
+	t.Parallel()
+
+	base, err := url.Parse("https://api.example.com/v1/items?page=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		want    []httpx.Link
+		wantErr error
+	}{
+		{
+			name:   "relative targets and multiple relations",
+			header: `<?page=3>; rel="next", </v1/items?page=1>; REL="prev first"`,
+			want: []httpx.Link{
+				{
+					URL:    mustParseURL(t, "https://api.example.com/v1/items?page=3"),
+					Params: map[string]string{},
+					Rel:    []string{"next"},
+				},
+				{
+					URL:    mustParseURL(t, "https://api.example.com/v1/items?page=1"),
+					Params: map[string]string{},
+					Rel:    []string{"prev", "first"},
+				},
+			},
+		},
+		{
+			name: "type, titles and extension attributes",
+			header: `<https://example.com/a,b>; rel=alternate; type="text/html"; ` +
+				`title="Plain \"quoted\""; title*=UTF-8'de'n%c3%a4chstes; hreflang=de; ` +
+				`foo="bar;baz"; flag; rel=ignored`,
+			want: []httpx.Link{
+				{
+					URL: mustParseURL(t, "https://example.com/a,b"),
+					Params: map[string]string{
+						"hreflang": "de",
+						"foo":      "bar;baz",
+						"flag":     "",
+					},
+					Type:  "text/html",
+					Title: "nächstes",
+					Rel:   []string{"alternate"},
+				},
+			},
+		},
+		{
+			name:   "extension relation types keep their case",
+			header: `<https://example.com/>; rel="https://example.com/Rels/Owner"; title="Owner"`,
+			want: []httpx.Link{
+				{
+					URL:    mustParseURL(t, "https://example.com/"),
+					Params: map[string]string{},
+					Title:  "Owner",
+					Rel:    []string{"https://example.com/Rels/Owner"},
+				},
+			},
+		},
+		{
+			name:   "empty header",
+			header: " , ",
+		},
+		{
+			name:    "missing target",
+			header:  `rel="next"`,
+			wantErr: httpx.ErrInvalidLinkHeader,
+		},
+		{
+			name:    "unterminated target",
+			header:  `<https://example.com/; rel="next"`,
+			wantErr: httpx.ErrInvalidLinkHeader,
+		},
+		{
+			name:    "unterminated quoted string",
+			header:  `<https://example.com/>; rel="next`,
+			wantErr: httpx.ErrInvalidLinkHeader,
+		},
+		{
+			name:    "missing separator",
+			header:  `<https://example.com/> rel="next"`,
+			wantErr: httpx.ErrInvalidLinkHeader,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := httpx.ParseLinkHeader(tt.header, base)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	t.Parallel()
+
+	resp := &http.Response{
+		Header: http.Header{
+			"Link": []string{
+				`</items?page=1>; rel="first"`,
+				`</items?page=3>; rel="next", </items?page=9>; rel="last"`,
+			},
+		},
+		Request: &http.Request{
+			URL: mustParseURL(t, "https://example.com/items?page=2"),
+		},
+	}
+
+	links, err := httpx.Links(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(links) != 3 {
+		t.Fatalf("got %d links, want 3", len(links))
+	}
+
+	next, ok := httpx.FindLink(links, "NEXT")
+	if !ok {
+		t.Fatal("got no next link")
+	}
+
+	if got := next.URL.String(); got != "https://example.com/items?page=3" {
+		t.Errorf("got next link %q, want %q", got, "https://example.com/items?page=3")
+	}
+
+	if _, ok = httpx.FindLink(links, "prev"); ok {
+		t.Error("got prev link, want none")
+	}
+}
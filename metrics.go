@@ -0,0 +1,338 @@
+package httpx
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Metrics defines the interface for collecting metrics about the requests made
+// by a Client. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveRequest records a single request attempt. The status code is zero
+	// if the attempt failed before a response was received.
+	ObserveRequest(host, method string, statusCode int, duration time.Duration)
+
+	// ObserveRetry records a retry attempt for the given host and method.
+	ObserveRetry(host, method string)
+
+	// ObserveRateLimiterWait records how long a request waited on the client's
+	// rate limiter before being sent.
+	ObserveRateLimiterWait(host string, wait time.Duration)
+
+	// ObserveCacheHit records a response served from the cache.
+	ObserveCacheHit(host string)
+
+	// ObserveCacheMiss records a cache lookup that found no response.
+	ObserveCacheMiss(host string)
+
+	// ObserveCacheSet records a response stored in the cache.
+	ObserveCacheSet(host string)
+}
+
+// DefaultLatencyBuckets returns the default upper bounds used by MemoryMetrics
+// to build its latency histograms.
+func DefaultLatencyBuckets() []time.Duration {
+	return []time.Duration{
+		5 * time.Millisecond,
+		10 * time.Millisecond,
+		25 * time.Millisecond,
+		50 * time.Millisecond,
+		100 * time.Millisecond,
+		250 * time.Millisecond,
+		500 * time.Millisecond,
+		1 * time.Second,
+		2500 * time.Millisecond,
+		5 * time.Second,
+		10 * time.Second,
+	}
+}
+
+// RequestLabels identifies a group of requests in a MetricsSnapshot.
+type RequestLabels struct {
+	// Host is the host the requests were sent to.
+	Host string
+
+	// Method is the HTTP method of the requests.
+	Method string
+
+	// StatusCode is the HTTP status code of the responses, or zero if no
+	// response was received.
+	StatusCode int
+}
+
+// RouteLabels identifies a host and method pair in a MetricsSnapshot.
+type RouteLabels struct {
+	// Host is the host the requests were sent to.
+	Host string
+
+	// Method is the HTTP method of the requests.
+	Method string
+}
+
+// Histogram is a snapshot of a cumulative histogram of durations.
+type Histogram struct {
+	// Buckets holds the upper bound of each bucket.
+	Buckets []time.Duration
+
+	// Counts holds the cumulative number of observations less than or equal
+	// to the upper bound at the same index in Buckets.
+	Counts []uint64
+
+	// Sum is the sum of all observed durations.
+	Sum time.Duration
+
+	// Count is the total number of observations.
+	Count uint64
+}
+
+// CacheStats holds cache counters for a single host.
+type CacheStats struct {
+	// Hits is the number of responses served from the cache.
+	Hits uint64
+
+	// Misses is the number of cache lookups that found no response.
+	Misses uint64
+
+	// Sets is the number of responses stored in the cache.
+	Sets uint64
+}
+
+// MetricsSnapshot is a point-in-time copy of the metrics collected by
+// MemoryMetrics.
+type MetricsSnapshot struct {
+	// Requests is the number of request attempts by host, method and status
+	// code.
+	Requests map[RequestLabels]uint64
+
+	// Retries is the number of retries by host and method.
+	Retries map[RouteLabels]uint64
+
+	// Latency is the request latency histogram by host and method.
+	Latency map[RouteLabels]Histogram
+
+	// RateLimiterWait is the rate limiter wait time histogram by host.
+	RateLimiterWait map[string]Histogram
+
+	// Cache is the cache counters by host.
+	Cache map[string]CacheStats
+}
+
+// MemoryMetrics is an in-memory implementation of the Metrics interface.
+type MemoryMetrics struct {
+	// requests counts request attempts by host, method and status code.
+	requests map[RequestLabels]uint64
+
+	// retries counts retries by host and method.
+	retries map[RouteLabels]uint64
+
+	// latency holds the latency histograms by host and method.
+	latency map[RouteLabels]*histogram
+
+	// rateLimiterWait holds the rate limiter wait histograms by host.
+	rateLimiterWait map[string]*histogram
+
+	// cache holds the cache counters by host.
+	cache map[string]*CacheStats
+
+	// buckets holds the upper bounds used for every histogram.
+	buckets []time.Duration
+
+	// mu protects the fields above.
+	mu sync.Mutex
+}
+
+// Compile-time check to ensure MemoryMetrics implements the Metrics interface.
+var _ Metrics = (*MemoryMetrics)(nil)
+
+// NewMemoryMetrics returns a new MemoryMetrics using the given histogram
+// buckets. If no buckets are given, DefaultLatencyBuckets is used.
+func NewMemoryMetrics(buckets ...time.Duration) *MemoryMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets()
+	}
+
+	sorted := make([]time.Duration, len(buckets))
+	copy(sorted, buckets)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	return &MemoryMetrics{
+		requests:        make(map[RequestLabels]uint64),
+		retries:         make(map[RouteLabels]uint64),
+		latency:         make(map[RouteLabels]*histogram),
+		rateLimiterWait: make(map[string]*histogram),
+		cache:           make(map[string]*CacheStats),
+		buckets:         sorted,
+	}
+}
+
+// ObserveRequest implements the Metrics interface.
+func (m *MemoryMetrics) ObserveRequest(host, method string, statusCode int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[RequestLabels{Host: host, Method: method, StatusCode: statusCode}]++
+
+	route := RouteLabels{Host: host, Method: method}
+
+	h, ok := m.latency[route]
+	if !ok {
+		h = newHistogram(m.buckets)
+		m.latency[route] = h
+	}
+
+	h.observe(duration)
+}
+
+// ObserveRetry implements the Metrics interface.
+func (m *MemoryMetrics) ObserveRetry(host, method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.retries[RouteLabels{Host: host, Method: method}]++
+}
+
+// ObserveRateLimiterWait implements the Metrics interface.
+func (m *MemoryMetrics) ObserveRateLimiterWait(host string, wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.rateLimiterWait[host]
+	if !ok {
+		h = newHistogram(m.buckets)
+		m.rateLimiterWait[host] = h
+	}
+
+	h.observe(wait)
+}
+
+// ObserveCacheHit implements the Metrics interface.
+func (m *MemoryMetrics) ObserveCacheHit(host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cacheStats(host).Hits++
+}
+
+// ObserveCacheMiss implements the Metrics interface.
+func (m *MemoryMetrics) ObserveCacheMiss(host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cacheStats(host).Misses++
+}
+
+// ObserveCacheSet implements the Metrics interface.
+func (m *MemoryMetrics) ObserveCacheSet(host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cacheStats(host).Sets++
+}
+
+// Snapshot returns a copy of the metrics collected so far.
+func (m *MemoryMetrics) Snapshot() *MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := &MetricsSnapshot{
+		Requests:        make(map[RequestLabels]uint64, len(m.requests)),
+		Retries:         make(map[RouteLabels]uint64, len(m.retries)),
+		Latency:         make(map[RouteLabels]Histogram, len(m.latency)),
+		RateLimiterWait: make(map[string]Histogram, len(m.rateLimiterWait)),
+		Cache:           make(map[string]CacheStats, len(m.cache)),
+	}
+
+	for k, v := range m.requests {
+		snapshot.Requests[k] = v
+	}
+
+	for k, v := range m.retries {
+		snapshot.Retries[k] = v
+	}
+
+	for k, v := range m.latency {
+		snapshot.Latency[k] = v.snapshot()
+	}
+
+	for k, v := range m.rateLimiterWait {
+		snapshot.RateLimiterWait[k] = v.snapshot()
+	}
+
+	for k, v := range m.cache {
+		snapshot.Cache[k] = *v
+	}
+
+	return snapshot
+}
+
+// cacheStats returns the cache counters for the given host, creating them if
+// needed. The caller must hold m.mu.
+func (m *MemoryMetrics) cacheStats(host string) *CacheStats {
+	stats, ok := m.cache[host]
+	if !ok {
+		stats = &CacheStats{}
+		m.cache[host] = stats
+	}
+
+	return stats
+}
+
+// histogram is a cumulative histogram of durations.
+type histogram struct {
+	buckets []time.Duration
+	counts  []uint64
+	sum     time.Duration
+	count   uint64
+}
+
+// newHistogram returns a new histogram with the given sorted bucket bounds.
+func newHistogram(buckets []time.Duration) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// observe adds a duration to the histogram.
+func (h *histogram) observe(d time.Duration) {
+	for i, bound := range h.buckets {
+		if d <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.sum += d
+	h.count++
+}
+
+// snapshot returns an exported copy of the histogram.
+func (h *histogram) snapshot() Histogram {
+	var (
+		buckets = make([]time.Duration, len(h.buckets))
+		counts  = make([]uint64, len(h.counts))
+	)
+
+	copy(buckets, h.buckets)
+	copy(counts, h.counts)
+
+	return Histogram{
+		Buckets: buckets,
+		Counts:  counts,
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+}
+
+// nopMetrics is a Metrics implementation that discards everything.
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveRequest(string, string, int, time.Duration) {}
+func (nopMetrics) ObserveRetry(string, string)                       {}
+func (nopMetrics) ObserveRateLimiterWait(string, time.Duration)      {}
+func (nopMetrics) ObserveCacheHit(string)                            {}
+func (nopMetrics) ObserveCacheMiss(string)                           {}
+func (nopMetrics) ObserveCacheSet(string)                            {}
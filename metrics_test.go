@@ -0,0 +1,85 @@
+package httpx_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+func TestMemoryMetrics(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	metrics := httpx.NewMemoryMetrics()
+
+	client := httpx.NewClientWithCache(nil)
+	client.Metrics = metrics
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy.MinRetryDelay = time.Millisecond
+	client.RetryPolicy.MaxRetryDelay = time.Millisecond
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(context.Background(), server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = httpx.DrainResponseBody(resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		snapshot = metrics.Snapshot()
+		route    = httpx.RouteLabels{Host: u.Host, Method: http.MethodGet}
+	)
+
+	if got := snapshot.Requests[httpx.RequestLabels{Host: u.Host, Method: http.MethodGet, StatusCode: http.StatusServiceUnavailable}]; got != 1 {
+		t.Errorf("Requests[503] = %d, want 1", got)
+	}
+
+	if got := snapshot.Requests[httpx.RequestLabels{Host: u.Host, Method: http.MethodGet, StatusCode: http.StatusOK}]; got != 1 {
+		t.Errorf("Requests[200] = %d, want 1", got)
+	}
+
+	if got := snapshot.Retries[route]; got != 1 {
+		t.Errorf("Retries = %d, want 1", got)
+	}
+
+	if got := snapshot.Latency[route].Count; got != 2 {
+		t.Errorf("Latency.Count = %d, want 2", got)
+	}
+
+	if got := snapshot.RateLimiterWait[u.Host].Count; got != 1 {
+		t.Errorf("RateLimiterWait.Count = %d, want 1", got)
+	}
+
+	want := httpx.CacheStats{Hits: 1, Misses: 1, Sets: 1}
+	if got := snapshot.Cache[u.Host]; got != want {
+		t.Errorf("Cache = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package msgpackx
+
+import (
+	"fmt"
+	"io"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MediaType is the media type of MessagePack bodies.
+const MediaType string = "application/msgpack"
+
+// Codec is an httpx.Codec for MessagePack bodies.
+type Codec struct{}
+
+// Compile-time check to ensure Codec implements the httpx.Codec interface.
+var _ httpx.Codec = Codec{}
+
+// MediaTypes returns the MessagePack media type, followed by the unregistered
+// media types commonly used for MessagePack.
+func (Codec) MediaTypes() []string {
+	return []string{MediaType, "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+// Encode writes v as MessagePack.
+func (Codec) Encode(w io.Writer, v any) error {
+	if err := msgpack.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Decode decodes a MessagePack value into v.
+func (Codec) Decode(r io.Reader, v any) error {
+	if err := msgpack.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,5 @@
+// Package msgpackx provides a [MessagePack] codec for [the httpx package].
+//
+// [MessagePack]: https://msgpack.org/
+// [the httpx package]: https://godocs.io/git.sr.ht/~jamesponddotco/httpx-go
+package msgpackx
@@ -0,0 +1,296 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrMultipartReplaced is returned by the reader of a MultipartBody when a
+// newer reader replaces it.
+const ErrMultipartReplaced xerrors.Error = "multipart body replaced by a newer reader"
+
+// _mediaTypeOctetStream is the Content-Type of files whose type cannot be
+// guessed from their name.
+const _mediaTypeOctetStream string = "application/octet-stream"
+
+// sequentialBodyKey is the context key marking requests whose body copies
+// cannot be read concurrently.
+type sequentialBodyKey struct{}
+
+// MultipartBody builds a multipart/form-data request body from fields and
+// files. Files are streamed through an [io.Pipe] when the body is read instead
+// of being buffered in memory.
+//
+// If every file added is an [io.Seeker], the body can be read more than once,
+// so requests built with it can be retried. Its copies share the files and
+// cannot be read concurrently, so requests built with it are not hedged.
+//
+// [io.Pipe]: https://godocs.io/io#Pipe
+// [io.Seeker]: https://godocs.io/io#Seeker
+type MultipartBody struct {
+	// reader is the reader returned by the last call to Reader.
+	reader *io.PipeReader
+
+	// done is closed once the last reader's writer finished.
+	done chan struct{}
+
+	// boundary is the multipart boundary.
+	boundary string
+
+	// parts holds the fields and files of the body, in order.
+	parts []multipartPart
+
+	// mu protects reader and done.
+	mu sync.Mutex
+}
+
+// multipartPart is a single field or file of a MultipartBody.
+type multipartPart struct {
+	// content is the content of a file.
+	content io.Reader
+
+	// name is the form field name.
+	name string
+
+	// value is the value of a field.
+	value string
+
+	// fileName is the name of a file.
+	fileName string
+
+	// contentType is the Content-Type of a file.
+	contentType string
+
+	// offset is the position of a seekable file when it was added.
+	offset int64
+
+	// isFile specifies whether the part is a file.
+	isFile bool
+}
+
+// NewMultipartBody returns a new, empty MultipartBody with a random boundary.
+func NewMultipartBody() *MultipartBody {
+	return &MultipartBody{
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+	}
+}
+
+// AddField adds a form field to the body.
+func (b *MultipartBody) AddField(name, value string) {
+	b.parts = append(b.parts, multipartPart{
+		name:  name,
+		value: value,
+	})
+}
+
+// AddFile adds a file to the body, read from content when the body is read.
+// If contentType is empty, it is guessed from the file name's extension.
+//
+// If content is an [io.Seeker], it is rewound to its current position every
+// time the body is read.
+//
+// [io.Seeker]: https://godocs.io/io#Seeker
+func (b *MultipartBody) AddFile(name, fileName, contentType string, content io.Reader) error {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(fileName))
+	}
+
+	if contentType == "" {
+		contentType = _mediaTypeOctetStream
+	}
+
+	var offset int64
+
+	if seeker, ok := content.(io.Seeker); ok {
+		var err error
+
+		offset, err = seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	b.parts = append(b.parts, multipartPart{
+		content:     content,
+		name:        name,
+		fileName:    fileName,
+		contentType: contentType,
+		offset:      offset,
+		isFile:      true,
+	})
+
+	return nil
+}
+
+// ContentType returns the Content-Type of the body, including its boundary.
+func (b *MultipartBody) ContentType() string {
+	return mime.FormatMediaType("multipart/form-data", map[string]string{"boundary": b.boundary})
+}
+
+// Replayable reports whether the body can be read more than once, which is the
+// case if every file is an [io.Seeker].
+//
+// [io.Seeker]: https://godocs.io/io#Seeker
+func (b *MultipartBody) Replayable() bool {
+	for _, part := range b.parts {
+		if _, ok := part.content.(io.Seeker); part.isFile && !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Reader returns a reader streaming the encoded body. Calling Reader again
+// closes the previous reader and, if the body is replayable, rewinds every
+// file, so that the new reader streams the body from the start.
+func (b *MultipartBody) Reader() (io.ReadCloser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.reader != nil {
+		b.reader.CloseWithError(ErrMultipartReplaced)
+
+		<-b.done
+
+		if err := b.rewind(); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		reader, writer = io.Pipe()
+		done           = make(chan struct{})
+	)
+
+	b.reader = reader
+	b.done = done
+
+	go func() {
+		defer close(done)
+
+		writer.CloseWithError(b.writeTo(writer))
+	}()
+
+	return reader, nil
+}
+
+// NewRequest returns a new request with the body, its Content-Type, and, if
+// the body is replayable, a GetBody function allowing the request to be
+// retried. The request is never hedged.
+func (b *MultipartBody) NewRequest(ctx context.Context, method, uri string) (*http.Request, error) {
+	body, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, sequentialBodyKey{}, true)
+
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
+	if err != nil {
+		body.Close()
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	req.Header.Set("Content-Type", b.ContentType())
+
+	if b.Replayable() {
+		req.GetBody = b.Reader
+	}
+
+	return req, nil
+}
+
+// PostMultipart is a convenience method for making POST requests with a
+// multipart/form-data body.
+func (c *Client) PostMultipart(ctx context.Context, uri string, body *MultipartBody) (*http.Response, error) {
+	req, err := body.NewRequest(ctx, http.MethodPost, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(ctx, req)
+}
+
+// rewind seeks every seekable file back to the position it was added at.
+func (b *MultipartBody) rewind() error {
+	for _, part := range b.parts {
+		seeker, ok := part.content.(io.Seeker)
+		if !ok {
+			continue
+		}
+
+		if _, err := seeker.Seek(part.offset, io.SeekStart); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeTo encodes the body to w.
+func (b *MultipartBody) writeTo(w io.Writer) error {
+	writer := multipart.NewWriter(w)
+
+	if err := writer.SetBoundary(b.boundary); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	for _, part := range b.parts {
+		if !part.isFile {
+			if err := writer.WriteField(part.name, part.value); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			continue
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(
+			`form-data; name="%s"; filename="%s"`,
+			escapeQuotes(part.name),
+			escapeQuotes(part.fileName),
+		))
+		header.Set("Content-Type", part.contentType)
+
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if _, err = io.Copy(partWriter, part.content); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// hasSequentialBody reports whether the copies of the request's body cannot be
+// read concurrently.
+func hasSequentialBody(req *http.Request) bool {
+	sequential, ok := req.Context().Value(sequentialBodyKey{}).(bool)
+
+	return ok && sequential
+}
+
+// escapeQuotes escapes backslashes and double quotes in a Content-Disposition
+// parameter value.
+func escapeQuotes(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,202 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+// multipartUpload is what a test server received in a multipart request.
+type multipartUpload struct {
+	field       string
+	fileName    string
+	contentType string
+	content     string
+}
+
+func TestClient_PostMultipart(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		content    func() io.Reader
+		wantStatus int
+		wantCalls  int
+	}{
+		{
+			name: "seekable file is replayed on retry",
+			content: func() io.Reader {
+				return bytes.NewReader([]byte("file contents"))
+			},
+			wantStatus: http.StatusOK,
+			wantCalls:  2,
+		},
+		{
+			name: "non-seekable file is not retried",
+			content: func() io.Reader {
+				return io.MultiReader(strings.NewReader("file contents"))
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu      sync.Mutex
+				uploads []multipartUpload
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+
+					return
+				}
+
+				file, header, err := r.FormFile("upload")
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+
+					return
+				}
+				defer file.Close()
+
+				content, err := io.ReadAll(file)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+
+					return
+				}
+
+				mu.Lock()
+				uploads = append(uploads, multipartUpload{
+					field:       r.FormValue("description"),
+					fileName:    header.Filename,
+					contentType: header.Header.Get("Content-Type"),
+					content:     string(content),
+				})
+				first := len(uploads) == 1
+				mu.Unlock()
+
+				if first {
+					w.WriteHeader(http.StatusServiceUnavailable)
+
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+			}))
+			t.Cleanup(server.Close)
+
+			client := httpx.NewClient()
+			client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+			client.RetryPolicy.MinRetryDelay = time.Millisecond
+			client.RetryPolicy.MaxRetryDelay = time.Millisecond
+			client.RetryPolicy.RetryNonIdempotent = true
+
+			body := httpx.NewMultipartBody()
+			body.AddField("description", "a \"quoted\" file")
+
+			if err := body.AddFile("upload", "report.txt", "", tt.content()); err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.PostMultipart(context.Background(), server.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if len(uploads) != tt.wantCalls {
+				t.Fatalf("server received %d uploads, want %d", len(uploads), tt.wantCalls)
+			}
+
+			want := multipartUpload{
+				field:       "a \"quoted\" file",
+				fileName:    "report.txt",
+				contentType: "text/plain; charset=utf-8",
+				content:     "file contents",
+			}
+
+			for i, got := range uploads {
+				if got != want {
+					t.Errorf("upload %d: got %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_HedgedMultipartPut(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	body := httpx.NewMultipartBody()
+	body.AddField("name", "report")
+
+	if err := body.AddFile("file", "report.txt", "", bytes.NewReader([]byte("contents"))); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := body.NewRequest(context.Background(), http.MethodPut, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.HedgePolicy = &httpx.HedgePolicy{Delay: 5 * time.Millisecond, MaxHedges: 2}
+
+	resp, err := client.Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server received %d requests, want 1", got)
+	}
+}
@@ -0,0 +1,272 @@
+package httpx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// PageFunc decodes a page of a paginated API, returning its items and the URL
+// of the next page, or nil if it is the last page. It must not close the
+// response body.
+type PageFunc[T any] func(resp *http.Response) (items []T, next *url.URL, err error)
+
+// Pager iterates over the items of a paginated API, fetching pages with GET
+// requests as needed.
+//
+// Every page after the first waits on the client's RateLimiter, if any, before
+// being fetched. Iteration stops at the last page, after MaxPages pages, at the
+// first error, or once the context passed to Next is canceled.
+//
+// A Pager is not safe for concurrent use.
+type Pager[T any] struct {
+	// MaxPages is the maximum number of pages to fetch. Zero means no limit.
+	MaxPages int
+
+	// client is the client used to fetch pages.
+	client *Client
+
+	// page decodes pages.
+	page PageFunc[T]
+
+	// next is the URL of the next page, or nil if there are no more pages.
+	next *url.URL
+
+	// err is the error that stopped the iteration, if any.
+	err error
+
+	// items holds the items of the current page not yet returned by Next.
+	items []T
+
+	// item is the last item returned by Next.
+	item T
+
+	// pages is the number of pages fetched so far.
+	pages int
+}
+
+// NewPager returns a new Pager starting at uri and decoding pages with the
+// given function.
+func NewPager[T any](client *Client, uri string, page PageFunc[T]) *Pager[T] {
+	pager := &Pager[T]{
+		client: client,
+		page:   page,
+	}
+
+	next, err := url.Parse(uri)
+	if err != nil {
+		pager.err = fmt.Errorf("%w", err)
+
+		return pager
+	}
+
+	pager.next = next
+
+	return pager
+}
+
+// NewLinkPager returns a new Pager for APIs returning pages as JSON arrays and
+// linking to the next page with an RFC 8288 Link header with rel="next".
+func NewLinkPager[T any](client *Client, uri string) *Pager[T] {
+	return NewPager(client, uri, func(resp *http.Response) ([]T, *url.URL, error) {
+		var items []T
+
+		if err := ReadJSON(resp, &items); err != nil {
+			return nil, nil, err
+		}
+
+		links, err := Links(resp)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if next, ok := FindLink(links, "next"); ok {
+			return items, next.URL, nil
+		}
+
+		return items, nil, nil
+	})
+}
+
+// NewCursorPager returns a new Pager for APIs returning pages as JSON objects
+// holding a cursor to the next page, which is sent back in the given query
+// parameter. Each page is decoded into a P, from which fn extracts the items
+// and the cursor. Iteration stops once the cursor is empty.
+func NewCursorPager[T, P any](client *Client, uri, param string, fn func(page P) (items []T, cursor string)) *Pager[T] {
+	return NewPager(client, uri, func(resp *http.Response) ([]T, *url.URL, error) {
+		var page P
+
+		if err := ReadJSON(resp, &page); err != nil {
+			return nil, nil, err
+		}
+
+		items, cursor := fn(page)
+		if cursor == "" {
+			return items, nil, nil
+		}
+
+		return items, withQuery(resp.Request.URL, param, cursor), nil
+	})
+}
+
+// NewOffsetPager returns a new Pager for APIs returning pages as JSON arrays
+// and selecting pages with an offset or page number in the given query
+// parameter. The parameter starts at start and increases by step for every
+// page, so step is one for page numbers and the page size for offsets.
+//
+// Iteration stops at the first page that is empty, shorter than the first page
+// or identical to the previous page, so that servers ignoring the parameter do
+// not cause an endless loop.
+func NewOffsetPager[T any](client *Client, uri, param string, start, step int) *Pager[T] {
+	var (
+		size     int
+		previous [sha256.Size]byte
+	)
+
+	pager := NewPager(client, uri, func(resp *http.Response) ([]T, *url.URL, error) {
+		var (
+			items []T
+			hash  = sha256.New()
+		)
+
+		// The whole body is hashed while it is decoded, so that repeated
+		// pages are detected from the bytes the server sent.
+		decoder := json.NewDecoder(io.TeeReader(resp.Body, hash))
+
+		if err := decoder.Decode(&items); err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrCannotDecodeJSON, err)
+		}
+
+		if _, err := io.Copy(hash, resp.Body); err != nil {
+			return nil, nil, fmt.Errorf("%w", err)
+		}
+
+		if len(items) == 0 {
+			return nil, nil, nil
+		}
+
+		var sum [sha256.Size]byte
+
+		hash.Sum(sum[:0])
+
+		if sum == previous {
+			return nil, nil, nil
+		}
+
+		if size == 0 {
+			size = len(items)
+		}
+
+		if len(items) < size {
+			return items, nil, nil
+		}
+
+		previous = sum
+
+		current, err := strconv.Atoi(resp.Request.URL.Query().Get(param))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w", err)
+		}
+
+		return items, withQuery(resp.Request.URL, param, strconv.Itoa(current+step)), nil
+	})
+
+	if pager.next != nil {
+		pager.next = withQuery(pager.next, param, strconv.Itoa(start))
+	}
+
+	return pager
+}
+
+// Next advances to the next item, fetching the next page if needed, and
+// reports whether there is one. Once Next returns false, Err reports the error
+// that stopped the iteration, if any.
+func (p *Pager[T]) Next(ctx context.Context) bool {
+	for p.err == nil {
+		if err := ctx.Err(); err != nil {
+			p.err = fmt.Errorf("%w", err)
+
+			return false
+		}
+
+		if len(p.items) > 0 {
+			p.item, p.items = p.items[0], p.items[1:]
+
+			return true
+		}
+
+		if p.next == nil || (p.MaxPages > 0 && p.pages >= p.MaxPages) {
+			return false
+		}
+
+		p.err = p.fetch(ctx)
+	}
+
+	return false
+}
+
+// Item returns the last item returned by Next.
+func (p *Pager[T]) Item() T {
+	return p.item
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (p *Pager[T]) Err() error {
+	return p.err
+}
+
+// fetch fetches and decodes the next page.
+func (p *Pager[T]) fetch(ctx context.Context) (err error) {
+	if p.pages > 0 && p.client.RateLimiter != nil {
+		if err = p.client.RateLimiter.Wait(ctx); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	resp, err := p.client.Get(ctx, p.next.String())
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err = closeStream(resp, err)
+	}()
+
+	if !IsSuccess(resp) {
+		return &Error{
+			URL:        resp.Request.URL,
+			Method:     resp.Request.Method,
+			Message:    "unexpected status for page",
+			StatusText: http.StatusText(resp.StatusCode),
+			StatusCode: resp.StatusCode,
+		}
+	}
+
+	items, next, err := p.page(resp)
+	if err != nil {
+		return err
+	}
+
+	p.items = items
+	p.next = next
+	p.pages++
+
+	return nil
+}
+
+// withQuery returns a copy of u with the given query parameter set.
+func withQuery(u *url.URL, param, value string) *url.URL {
+	next := *u
+
+	query := next.Query()
+	query.Set(param, value)
+
+	next.RawQuery = query.Encode()
+
+	return &next
+}
@@ -0,0 +1,306 @@
+package httpx_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+// cursorPage is a page of a cursor-paginated API.
+type cursorPage struct {
+	Next  string `json:"next"`
+	Items []int  `json:"items"`
+}
+
+func TestPager(t *testing.T) {
+	t.Parallel()
+
+	pages := [][]int{{1, 2}, {3, 4}, {5}}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		pager   func(client *httpx.Client, uri string) *httpx.Pager[int]
+	}{
+		{
+			name: "link",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+				if page < len(pages)-1 {
+					w.Header().Add("Link", `</items?page=0>; rel="first"`)
+					w.Header().Add("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=2>; rel="last"`, page+1))
+				}
+
+				json.NewEncoder(w).Encode(pages[page])
+			},
+			pager: func(client *httpx.Client, uri string) *httpx.Pager[int] {
+				return httpx.NewLinkPager[int](client, uri)
+			},
+		},
+		{
+			name: "cursor",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+
+				resp := cursorPage{Items: pages[page]}
+				if page < len(pages)-1 {
+					resp.Next = strconv.Itoa(page + 1)
+				}
+
+				json.NewEncoder(w).Encode(resp)
+			},
+			pager: func(client *httpx.Client, uri string) *httpx.Pager[int] {
+				return httpx.NewCursorPager(client, uri, "cursor", func(page cursorPage) ([]int, string) {
+					return page.Items, page.Next
+				})
+			},
+		},
+		{
+			name: "offset",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+				items := []int{}
+				if page <= len(pages) {
+					items = pages[page-1]
+				}
+
+				json.NewEncoder(w).Encode(items)
+			},
+			pager: func(client *httpx.Client, uri string) *httpx.Pager[int] {
+				return httpx.NewOffsetPager[int](client, uri, "page", 1, 1)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(tt.handler)
+			t.Cleanup(server.Close)
+
+			client := httpx.NewClient()
+			client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+			var (
+				pager = tt.pager(client, server.URL+"/items")
+				got   []int
+			)
+
+			for pager.Next(context.Background()) {
+				got = append(got, pager.Item())
+			}
+
+			if err := pager.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if want := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPager_Errors(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Link", `<?page=2>; rel="next"`)
+		json.NewEncoder(w).Encode([]int{1, 2})
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	t.Run("status", func(t *testing.T) {
+		t.Parallel()
+
+		pager := httpx.NewLinkPager[int](client, server.URL)
+
+		var count int
+
+		for pager.Next(context.Background()) {
+			count++
+		}
+
+		var httpErr *httpx.Error
+		if !errors.As(pager.Err(), &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+			t.Errorf("got error %v, want 404 *httpx.Error", pager.Err())
+		}
+
+		if count != 2 {
+			t.Errorf("got %d items, want 2", count)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		pager := httpx.NewLinkPager[int](client, server.URL)
+
+		if !pager.Next(ctx) {
+			t.Fatalf("got no item: %v", pager.Err())
+		}
+
+		cancel()
+
+		if pager.Next(ctx) {
+			t.Error("got item after cancellation")
+		}
+
+		if !errors.Is(pager.Err(), context.Canceled) {
+			t.Errorf("got error %v, want %v", pager.Err(), context.Canceled)
+		}
+	})
+}
+
+func TestPager_Stops(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		pager    func(client *httpx.Client, uri string) *httpx.Pager[int]
+		want     []int
+		requests int32
+	}{
+		{
+			name: "offset ignored by server",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				json.NewEncoder(w).Encode([]int{1, 2})
+			},
+			pager: func(client *httpx.Client, uri string) *httpx.Pager[int] {
+				return httpx.NewOffsetPager[int](client, uri, "offset", 0, 2)
+			},
+			want:     []int{1, 2},
+			requests: 2,
+		},
+		{
+			name: "maximum pages",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+				w.Header().Set("Link", fmt.Sprintf(`<?page=%d>; rel="next"`, page+1))
+				json.NewEncoder(w).Encode([]int{page})
+			},
+			pager: func(client *httpx.Client, uri string) *httpx.Pager[int] {
+				pager := httpx.NewLinkPager[int](client, uri)
+				pager.MaxPages = 3
+
+				return pager
+			},
+			want:     []int{0, 1, 2},
+			requests: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				tt.handler(w, r)
+			}))
+			t.Cleanup(server.Close)
+
+			client := httpx.NewClient()
+			client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+			var (
+				pager = tt.pager(client, server.URL+"/items")
+				got   []int
+			)
+
+			for pager.Next(context.Background()) {
+				got = append(got, pager.Item())
+			}
+
+			if err := pager.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("got %d requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+// unmarshalOnly is a page item that can be decoded but not encoded.
+type unmarshalOnly struct {
+	ID int
+}
+
+func (unmarshalOnly) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("cannot marshal")
+}
+
+func TestOffsetPager_UnmarshalOnly(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+		if page > 2 {
+			fmt.Fprint(w, `[]`)
+
+			return
+		}
+
+		fmt.Fprintf(w, `[{"ID": %d}]`, page)
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	var (
+		pager = httpx.NewOffsetPager[unmarshalOnly](client, server.URL, "page", 1, 1)
+		got   []int
+	)
+
+	for pager.Next(context.Background()) {
+		got = append(got, pager.Item().ID)
+	}
+
+	if err := pager.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,179 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Progress describes how much of a request or response body was transferred.
+type Progress struct {
+	// Transferred is the number of bytes transferred so far.
+	Transferred int64
+
+	// Total is the size of the body, taken from its Content-Length, or -1 if
+	// unknown.
+	Total int64
+
+	// Rate is the average transfer rate so far, in bytes per second.
+	Rate float64
+}
+
+// Percent returns the percentage of the body transferred, or -1 if the size of
+// the body is unknown.
+func (p Progress) Percent() float64 {
+	if p.Total < 0 {
+		return -1
+	}
+
+	if p.Total == 0 {
+		return 100
+	}
+
+	return float64(p.Transferred) / float64(p.Total) * 100
+}
+
+// ProgressFunc is called with the progress of a body transfer every time part
+// of the body is read. It is called from the goroutine reading the body, which
+// for request bodies is a goroutine of the transport.
+type ProgressFunc func(Progress)
+
+// TrackRequestProgress wraps the body of the request so that fn is called as
+// the body is sent. If the request can be retried, every retry reports its
+// progress from zero.
+func TrackRequestProgress(req *http.Request, fn ProgressFunc) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	total := req.ContentLength
+	if total == 0 {
+		total = -1
+	}
+
+	req.Body = newProgressReader(req.Body, total, fn)
+
+	if req.GetBody == nil {
+		return
+	}
+
+	getBody := req.GetBody
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		body, err := getBody()
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		return newProgressReader(body, total, fn), nil
+	}
+}
+
+// TrackResponseProgress wraps the body of the response so that fn is called as
+// the body is read.
+func TrackResponseProgress(resp *http.Response, fn ProgressFunc) {
+	resp.Body = newProgressReader(resp.Body, resp.ContentLength, fn)
+}
+
+// WithUploadProgress returns a copy of ctx that makes Client.Do track the
+// progress of the bodies of requests made with it, as TrackRequestProgress
+// does. It allows tracking uploads made through helpers such as Client.Post.
+func WithUploadProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, uploadProgressKey{}, fn)
+}
+
+// WithDownloadProgress returns a copy of ctx that makes Client.Do track the
+// progress of the bodies of responses to requests made with it, as
+// TrackResponseProgress does. It allows tracking downloads made through
+// helpers such as Client.Get.
+func WithDownloadProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, downloadProgressKey{}, fn)
+}
+
+// uploadProgressKey is the context key used to store the ProgressFunc of
+// request bodies.
+type uploadProgressKey struct{}
+
+// downloadProgressKey is the context key used to store the ProgressFunc of
+// response bodies.
+type downloadProgressKey struct{}
+
+// trackUpload tracks the progress of the request's body if its context has an
+// upload ProgressFunc.
+func trackUpload(req *http.Request) {
+	if fn := progressFunc(req.Context(), uploadProgressKey{}); fn != nil {
+		TrackRequestProgress(req, fn)
+	}
+}
+
+// trackDownload tracks the progress of the response's body if the context of
+// the request has a download ProgressFunc.
+func trackDownload(req *http.Request, resp *http.Response) {
+	if fn := progressFunc(req.Context(), downloadProgressKey{}); fn != nil {
+		TrackResponseProgress(resp, fn)
+	}
+}
+
+// progressFunc returns the ProgressFunc stored in ctx under the given key, if
+// any.
+func progressFunc(ctx context.Context, key any) ProgressFunc {
+	fn, ok := ctx.Value(key).(ProgressFunc)
+	if !ok {
+		return nil
+	}
+
+	return fn
+}
+
+// progressReader is an io.ReadCloser that reports the progress of reads from
+// the underlying reader.
+type progressReader struct {
+	io.ReadCloser
+
+	// start is when the transfer started.
+	start time.Time
+
+	// fn is called after every read.
+	fn ProgressFunc
+
+	// transferred is the number of bytes read so far.
+	transferred int64
+
+	// total is the size of the body, or -1 if unknown.
+	total int64
+}
+
+// newProgressReader returns a new progressReader reading from body.
+func newProgressReader(body io.ReadCloser, total int64, fn ProgressFunc) *progressReader {
+	return &progressReader{
+		ReadCloser: body,
+		start:      time.Now(),
+		fn:         fn,
+		total:      total,
+	}
+}
+
+// Read reads from the underlying reader and reports the progress.
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+
+	if n > 0 {
+		r.transferred += int64(n)
+
+		var rate float64
+
+		if elapsed := time.Since(r.start).Seconds(); elapsed > 0 {
+			rate = float64(r.transferred) / elapsed
+		}
+
+		r.fn(Progress{
+			Transferred: r.transferred,
+			Total:       r.total,
+			Rate:        rate,
+		})
+	}
+
+	return n, err //nolint:wrapcheck // Callers expect io.EOF as is.
+}
@@ -0,0 +1,169 @@
+package httpx_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+func TestProgress_Percent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		progress httpx.Progress
+		want     float64
+	}{
+		{
+			name:     "unknown total",
+			progress: httpx.Progress{Transferred: 10, Total: -1},
+			want:     -1,
+		},
+		{
+			name:     "empty body",
+			progress: httpx.Progress{Total: 0},
+			want:     100,
+		},
+		{
+			name:     "partial",
+			progress: httpx.Progress{Transferred: 25, Total: 100},
+			want:     25,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.progress.Percent(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_UploadProgress(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			t.Error(err)
+		}
+
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy.MinRetryDelay = time.Millisecond
+	client.RetryPolicy.MaxRetryDelay = time.Millisecond
+	client.RetryPolicy.RetryNonIdempotent = true
+
+	var (
+		mu       sync.Mutex
+		progress []httpx.Progress
+		body     = strings.Repeat("x", 1000)
+	)
+
+	ctx := httpx.WithUploadProgress(context.Background(), func(p httpx.Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		progress = append(progress, p)
+	})
+
+	resp, err := client.Post(ctx, server.URL, "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = httpx.DrainResponseBody(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var complete int
+
+	for _, p := range progress {
+		if p.Total != int64(len(body)) {
+			t.Errorf("got total %d, want %d", p.Total, len(body))
+		}
+
+		if p.Transferred == p.Total {
+			complete++
+		}
+	}
+
+	if complete != 2 {
+		t.Errorf("got %d complete transfers, want one per attempt", complete)
+	}
+}
+
+func TestClient_DownloadProgress(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("y", 1000)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClientWithCache(nil)
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	// The second request is served from the cache.
+	for i := 0; i < 2; i++ {
+		var last httpx.Progress
+
+		ctx := httpx.WithDownloadProgress(context.Background(), func(p httpx.Progress) {
+			last = p
+		})
+
+		resp, err := client.Get(ctx, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+
+		if string(got) != body {
+			t.Errorf("request %d: got body of %d bytes, want %d", i, len(got), len(body))
+		}
+
+		if last.Transferred != int64(len(body)) || last.Total != int64(len(body)) {
+			t.Errorf("request %d: got progress %+v, want %d of %d bytes", i, last, len(body), len(body))
+		}
+	}
+}
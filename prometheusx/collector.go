@@ -0,0 +1,162 @@
+package prometheusx
+
+import (
+	"strconv"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultNamespace is the default namespace used for metric names.
+const DefaultNamespace string = "httpx"
+
+// Collector implements both the httpx.Metrics and the prometheus.Collector
+// interfaces, exposing the metrics reported by an httpx.Client to Prometheus.
+type Collector struct {
+	// requests counts request attempts by host, method and status code.
+	requests *prometheus.CounterVec
+
+	// retries counts retries by host and method.
+	retries *prometheus.CounterVec
+
+	// cacheHits counts responses served from the cache by host.
+	cacheHits *prometheus.CounterVec
+
+	// cacheMisses counts cache lookups that found no response by host.
+	cacheMisses *prometheus.CounterVec
+
+	// cacheSets counts responses stored in the cache by host.
+	cacheSets *prometheus.CounterVec
+
+	// latency observes request latency by host and method.
+	latency *prometheus.HistogramVec
+
+	// rateLimiterWait observes rate limiter wait time by host.
+	rateLimiterWait *prometheus.HistogramVec
+}
+
+// Compile-time check to ensure Collector implements the httpx.Metrics and
+// prometheus.Collector interfaces.
+var (
+	_ httpx.Metrics        = (*Collector)(nil)
+	_ prometheus.Collector = (*Collector)(nil)
+)
+
+// NewCollector returns a new Collector using the given namespace for metric
+// names. If namespace is empty, DefaultNamespace is used.
+//
+// The collector must be registered with a prometheus.Registerer before its
+// metrics are exported.
+func NewCollector(namespace string) *Collector {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Total number of HTTP request attempts.",
+		}, []string{"host", "method", "code"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Total number of HTTP request retries.",
+		}, []string{"host", "method"}),
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_hits_total",
+			Help:      "Total number of responses served from the cache.",
+		}, []string{"host"}),
+		cacheMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_misses_total",
+			Help:      "Total number of cache lookups that found no response.",
+		}, []string{"host"}),
+		cacheSets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_sets_total",
+			Help:      "Total number of responses stored in the cache.",
+		}, []string{"host"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP request attempts.",
+			Buckets:   buckets(),
+		}, []string{"host", "method"}),
+		rateLimiterWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rate_limiter_wait_seconds",
+			Help:      "Time spent waiting on the client-side rate limiter.",
+			Buckets:   buckets(),
+		}, []string{"host"}),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.retries.Describe(ch)
+	c.cacheHits.Describe(ch)
+	c.cacheMisses.Describe(ch)
+	c.cacheSets.Describe(ch)
+	c.latency.Describe(ch)
+	c.rateLimiterWait.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.retries.Collect(ch)
+	c.cacheHits.Collect(ch)
+	c.cacheMisses.Collect(ch)
+	c.cacheSets.Collect(ch)
+	c.latency.Collect(ch)
+	c.rateLimiterWait.Collect(ch)
+}
+
+// ObserveRequest implements the httpx.Metrics interface.
+func (c *Collector) ObserveRequest(host, method string, statusCode int, duration time.Duration) {
+	c.requests.WithLabelValues(host, method, strconv.Itoa(statusCode)).Inc()
+	c.latency.WithLabelValues(host, method).Observe(duration.Seconds())
+}
+
+// ObserveRetry implements the httpx.Metrics interface.
+func (c *Collector) ObserveRetry(host, method string) {
+	c.retries.WithLabelValues(host, method).Inc()
+}
+
+// ObserveRateLimiterWait implements the httpx.Metrics interface.
+func (c *Collector) ObserveRateLimiterWait(host string, wait time.Duration) {
+	c.rateLimiterWait.WithLabelValues(host).Observe(wait.Seconds())
+}
+
+// ObserveCacheHit implements the httpx.Metrics interface.
+func (c *Collector) ObserveCacheHit(host string) {
+	c.cacheHits.WithLabelValues(host).Inc()
+}
+
+// ObserveCacheMiss implements the httpx.Metrics interface.
+func (c *Collector) ObserveCacheMiss(host string) {
+	c.cacheMisses.WithLabelValues(host).Inc()
+}
+
+// ObserveCacheSet implements the httpx.Metrics interface.
+func (c *Collector) ObserveCacheSet(host string) {
+	c.cacheSets.WithLabelValues(host).Inc()
+}
+
+// buckets returns httpx.DefaultLatencyBuckets converted to seconds.
+func buckets() []float64 {
+	var (
+		durations = httpx.DefaultLatencyBuckets()
+		seconds   = make([]float64, 0, len(durations))
+	)
+
+	for _, d := range durations {
+		seconds = append(seconds, d.Seconds())
+	}
+
+	return seconds
+}
@@ -0,0 +1,63 @@
+package prometheusx_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go/prometheusx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	collector := prometheusx.NewCollector("")
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatal(err)
+	}
+
+	collector.ObserveRequest("example.com", http.MethodGet, http.StatusOK, 10*time.Millisecond)
+	collector.ObserveRequest("example.com", http.MethodGet, http.StatusOK, 20*time.Millisecond)
+	collector.ObserveRetry("example.com", http.MethodGet)
+	collector.ObserveRateLimiterWait("example.com", time.Millisecond)
+	collector.ObserveCacheHit("example.com")
+	collector.ObserveCacheMiss("example.com")
+	collector.ObserveCacheSet("example.com")
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]float64, len(families))
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			switch {
+			case metric.GetCounter() != nil:
+				got[family.GetName()] += metric.GetCounter().GetValue()
+			case metric.GetHistogram() != nil:
+				got[family.GetName()] += float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+
+	want := map[string]float64{
+		"httpx_requests_total":            2,
+		"httpx_request_duration_seconds":  2,
+		"httpx_retries_total":             1,
+		"httpx_rate_limiter_wait_seconds": 1,
+		"httpx_cache_hits_total":          1,
+		"httpx_cache_misses_total":        1,
+		"httpx_cache_sets_total":          1,
+	}
+
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %v, want %v", name, got[name], value)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+// Package prometheusx provides a [Prometheus] collector for the metrics
+// reported by [the httpx package].
+//
+// [Prometheus]: https://prometheus.io/
+// [the httpx package]: https://godocs.io/git.sr.ht/~jamesponddotco/httpx-go
+package prometheusx
@@ -0,0 +1,64 @@
+package protobufx
+
+import (
+	"fmt"
+	"io"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"google.golang.org/protobuf/proto"
+)
+
+// MediaType is the media type of Protocol Buffers bodies.
+const MediaType string = "application/protobuf"
+
+// Codec is an httpx.Codec for Protocol Buffers bodies in the binary wire
+// format. It encodes and decodes proto.Message values only.
+type Codec struct{}
+
+// Compile-time check to ensure Codec implements the httpx.Codec interface.
+var _ httpx.Codec = Codec{}
+
+// MediaTypes returns the Protocol Buffers media type, followed by the
+// unregistered media types commonly used for Protocol Buffers.
+func (Codec) MediaTypes() []string {
+	return []string{MediaType, "application/x-protobuf", "application/vnd.google.protobuf"}
+}
+
+// Encode writes v, which must be a proto.Message, in the binary wire format.
+func (Codec) Encode(w io.Writer, v any) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w: %T is not a proto.Message", httpx.ErrUnsupportedValue, v)
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Decode decodes a message in the binary wire format into v, which must be a
+// proto.Message.
+func (Codec) Decode(r io.Reader, v any) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w: %T is not a proto.Message", httpx.ErrUnsupportedValue, v)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = proto.Unmarshal(data, msg); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
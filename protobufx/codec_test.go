@@ -0,0 +1,54 @@
+package protobufx_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"git.sr.ht/~jamesponddotco/httpx-go/protobufx"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodec(t *testing.T) {
+	t.Parallel()
+
+	codecs := httpx.NewCodecs(protobufx.Codec{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+
+		if _, err := io.Copy(w, r.Body); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	req, err := codecs.NewRequest(context.Background(), http.MethodPost, server.URL, protobufx.MediaType, wrapperspb.String("widget"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := httpx.NewClientWithCache(nil).Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got wrapperspb.StringValue
+
+	if err = codecs.ReadBody(resp, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.GetValue() != "widget" {
+		t.Errorf("ReadBody() = %q, want %q", got.GetValue(), "widget")
+	}
+
+	if _, err = codecs.NewRequest(context.Background(), http.MethodPost, server.URL, protobufx.MediaType, "widget"); !errors.Is(err, httpx.ErrUnsupportedValue) {
+		t.Errorf("NewRequest() error = %v, want %v", err, httpx.ErrUnsupportedValue)
+	}
+}
@@ -0,0 +1,6 @@
+// Package protobufx provides a [Protocol Buffers] codec for [the httpx
+// package].
+//
+// [Protocol Buffers]: https://protobuf.dev/
+// [the httpx package]: https://godocs.io/git.sr.ht/~jamesponddotco/httpx-go
+package protobufx
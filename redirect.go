@@ -0,0 +1,147 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrTooManyRedirects is returned when a request exceeds the maximum
+	// number of redirects allowed by a RedirectPolicy.
+	ErrTooManyRedirects xerrors.Error = "too many redirects"
+
+	// ErrRedirectCrossHost is returned when a request is redirected to a
+	// different host and the RedirectPolicy only allows same-host redirects.
+	ErrRedirectCrossHost xerrors.Error = "redirect to a different host not allowed"
+
+	// ErrRedirectDowngrade is returned when a request is redirected from HTTPS
+	// to HTTP and the RedirectPolicy does not allow downgrades.
+	ErrRedirectDowngrade xerrors.Error = "redirect from HTTPS to HTTP not allowed"
+)
+
+// _defaultMaxRedirects is the default maximum number of redirects to follow.
+const _defaultMaxRedirects int = 10
+
+// RedirectPolicy defines a policy for following HTTP redirects.
+type RedirectPolicy struct {
+	// MaxRedirects is the maximum number of redirects to follow for a single
+	// request. Zero means the default of 10, and a negative value disables
+	// redirects, returning the redirect response itself to the caller.
+	MaxRedirects int
+
+	// SameHost specifies whether redirects should only be followed if they
+	// point to the same host as the original request.
+	SameHost bool
+
+	// AllowDowngrade specifies whether redirects from HTTPS to HTTP should be
+	// followed.
+	AllowDowngrade bool
+
+	// KeepSensitiveHeaders specifies whether the Authorization, Cookie and
+	// similar headers should be kept when a request is redirected to a
+	// different origin. By default, they are removed.
+	KeepSensitiveHeaders bool
+}
+
+// DefaultRedirectPolicy returns a RedirectPolicy with sensible defaults for
+// following redirects safely.
+func DefaultRedirectPolicy() *RedirectPolicy {
+	return &RedirectPolicy{
+		MaxRedirects: _defaultMaxRedirects,
+	}
+}
+
+// CheckRedirect implements the policy. It matches the signature of
+// [http.Client.CheckRedirect] and can be used directly as Client.CheckRedirect.
+//
+// [http.Client.CheckRedirect]: https://godocs.io/net/http#Client
+func (p *RedirectPolicy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) == 0 {
+		return nil
+	}
+
+	var (
+		original = via[0].URL
+		previous = via[len(via)-1].URL
+	)
+
+	maxRedirects := p.maxRedirects()
+	if maxRedirects < 0 {
+		return http.ErrUseLastResponse
+	}
+
+	if len(via) > maxRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects", ErrTooManyRedirects, maxRedirects)
+	}
+
+	if p.SameHost && !strings.EqualFold(req.URL.Hostname(), original.Hostname()) {
+		return fmt.Errorf("%w: %s", ErrRedirectCrossHost, req.URL.Host)
+	}
+
+	if !p.AllowDowngrade && strings.EqualFold(previous.Scheme, "https") && !strings.EqualFold(req.URL.Scheme, "https") {
+		return fmt.Errorf("%w: %s", ErrRedirectDowngrade, req.URL)
+	}
+
+	if !p.KeepSensitiveHeaders && !sameOrigin(req.URL, original) {
+		for _, header := range sensitiveHeaders() {
+			req.Header.Del(header)
+		}
+	}
+
+	return nil
+}
+
+// maxRedirects returns the maximum number of redirects to follow, or a
+// negative value if redirects are disabled.
+func (p *RedirectPolicy) maxRedirects() int {
+	if p.MaxRedirects == 0 {
+		return _defaultMaxRedirects
+	}
+
+	return p.MaxRedirects
+}
+
+// RedirectChain returns the URLs visited to obtain the given response, in
+// order, starting with the original request and ending with the request that
+// produced the response. It returns nil if the response carries no request.
+func RedirectChain(resp *http.Response) []*url.URL {
+	var chain []*url.URL
+
+	for req := resp.Request; req != nil; {
+		chain = append(chain, req.URL)
+
+		if req.Response == nil {
+			break
+		}
+
+		req = req.Response.Request
+	}
+
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+
+	return chain
+}
+
+// sensitiveHeaders returns the headers removed from requests redirected to a
+// different origin.
+func sensitiveHeaders() []string {
+	return []string{
+		"Authorization",
+		"Proxy-Authorization",
+		"Cookie",
+		"Cookie2",
+	}
+}
+
+// sameOrigin reports whether two URLs share the same scheme, host and port.
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Hostname(), b.Hostname()) &&
+		defaultPort(a) == defaultPort(b)
+}
@@ -0,0 +1,143 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+)
+
+func TestRedirectPolicy(t *testing.T) {
+	t.Parallel()
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" || r.Header.Get("Proxy-Authorization") != "" || r.Header.Get("Cookie") != "" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(other.Close)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b", http.StatusFound)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/c", http.StatusFound)
+	})
+	mux.HandleFunc("/c", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL, http.StatusFound)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name       string
+		path       string
+		policy     *httpx.RedirectPolicy
+		wantStatus int
+		wantChain  []string
+		wantErr    error
+	}{
+		{
+			name:       "follows redirects",
+			path:       "/a",
+			policy:     httpx.DefaultRedirectPolicy(),
+			wantStatus: http.StatusOK,
+			wantChain:  []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"},
+		},
+		{
+			name:       "zero value follows redirects",
+			path:       "/a",
+			policy:     &httpx.RedirectPolicy{},
+			wantStatus: http.StatusOK,
+			wantChain:  []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"},
+		},
+		{
+			name:       "negative maximum disables redirects",
+			path:       "/a",
+			policy:     &httpx.RedirectPolicy{MaxRedirects: -1},
+			wantStatus: http.StatusFound,
+			wantChain:  []string{server.URL + "/a"},
+		},
+		{
+			name:    "too many redirects",
+			path:    "/a",
+			policy:  &httpx.RedirectPolicy{MaxRedirects: 1},
+			wantErr: httpx.ErrTooManyRedirects,
+		},
+		{
+			name:       "strips credentials on cross-origin redirects",
+			path:       "/other",
+			policy:     httpx.DefaultRedirectPolicy(),
+			wantStatus: http.StatusOK,
+			wantChain:  []string{server.URL + "/other", other.URL},
+		},
+		{
+			name:       "keeps credentials when asked to",
+			path:       "/other",
+			policy:     &httpx.RedirectPolicy{KeepSensitiveHeaders: true},
+			wantStatus: http.StatusForbidden,
+			wantChain:  []string{server.URL + "/other", other.URL},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := httpx.NewClient()
+			client.RedirectPolicy = tt.policy
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+tt.path, http.NoBody)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer secret")
+			req.Header.Set("Proxy-Authorization", "Basic c2VjcmV0")
+			req.Header.Set("Cookie", "session=secret")
+
+			resp, err := client.Do(context.Background(), req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			chain := httpx.RedirectChain(resp)
+			if len(chain) != len(tt.wantChain) {
+				t.Fatalf("RedirectChain() = %v, want %v", chain, tt.wantChain)
+			}
+
+			for i, u := range chain {
+				if u.String() != tt.wantChain[i] {
+					t.Errorf("RedirectChain()[%d] = %s, want %s", i, u, tt.wantChain[i])
+				}
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package httpx
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -25,11 +26,18 @@ const (
 
 	// ErrNilVal is returned when a nil value is passed to a function.
 	ErrNilValue xerrors.Error = "val cannot be nil"
+
+	// ErrNotJSONArray is returned when a response expected to contain a JSON
+	// array contains something else.
+	ErrNotJSONArray xerrors.Error = "response is not a JSON array"
 )
 
 // ReadJSON reads the body of an HTTP response and unmarshals it into the given
 // struct. The provided val parameter should be a pointer to a struct where the
 // JSON data will be unmarshalled.
+//
+// ReadJSON ignores the Content-Type of the response; use ReadBody to pick the
+// decoder by Content-Type and reject unexpected bodies such as HTML error pages.
 func ReadJSON(resp *http.Response, val any) error {
 	decoder := json.NewDecoder(resp.Body)
 
@@ -40,6 +48,79 @@ func ReadJSON(resp *http.Response, val any) error {
 	return nil
 }
 
+// ReadJSONArray decodes a response whose body is a top-level JSON array one
+// element at a time, calling fn with each element. Only one element is held in
+// memory at a time, so arbitrarily large arrays can be processed.
+//
+// Decoding stops at the first error returned by fn, which is then returned. The
+// response body is drained and closed once the whole array has been read, or
+// closed without draining if decoding stopped early.
+func ReadJSONArray[T any](resp *http.Response, fn func(T) error) (err error) {
+	defer func() {
+		err = closeStream(resp, err)
+	}()
+
+	decoder := json.NewDecoder(resp.Body)
+
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotDecodeJSON, err)
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return ErrNotJSONArray
+	}
+
+	for decoder.More() {
+		var val T
+
+		if err = decoder.Decode(&val); err != nil {
+			return fmt.Errorf("%w: %w", ErrCannotDecodeJSON, err)
+		}
+
+		if err = fn(val); err != nil {
+			return err
+		}
+	}
+
+	if _, err = decoder.Token(); err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotDecodeJSON, err)
+	}
+
+	return nil
+}
+
+// ReadNDJSON decodes a response whose body is newline-delimited JSON one value
+// at a time, calling fn with each value. Only one value is held in memory at a
+// time, so arbitrarily large streams can be processed.
+//
+// Decoding stops at the first error returned by fn, which is then returned. The
+// response body is drained and closed once the whole stream has been read, or
+// closed without draining if decoding stopped early.
+func ReadNDJSON[T any](resp *http.Response, fn func(T) error) (err error) {
+	defer func() {
+		err = closeStream(resp, err)
+	}()
+
+	decoder := json.NewDecoder(resp.Body)
+
+	for {
+		var val T
+
+		if err = decoder.Decode(&val); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("%w: %w", ErrCannotDecodeJSON, err)
+		}
+
+		if err = fn(val); err != nil {
+			return err
+		}
+	}
+}
+
 // WriteJSON writes a given struct to a JSON payload that can be used for HTTP
 // requests. The provided val parameter should be a pointer to a struct where
 // the JSON data will be marshaled.
@@ -79,6 +160,22 @@ func DrainResponseBody(resp *http.Response) error {
 	return nil
 }
 
+// closeStream cleans up the body of a streamed response. If err is nil the
+// body is drained and closed, otherwise it is only closed, since draining the
+// remainder of a large stream could take a long time. It returns err, or the
+// error from cleaning up if err is nil.
+func closeStream(resp *http.Response, err error) error {
+	if err == nil {
+		return DrainResponseBody(resp)
+	}
+
+	if closeErr := resp.Body.Close(); closeErr != nil {
+		return fmt.Errorf("%w: %w", err, closeErr)
+	}
+
+	return err
+}
+
 // IsSuccess checks if the HTTP response has a successful status code (2xx).
 func IsSuccess(resp *http.Response) bool {
 	return resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices
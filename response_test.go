@@ -11,8 +11,10 @@ import (
 	"reflect"
 	"strconv"
 	"testing"
+	"time"
 
 	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
 )
 
 type TestStruct struct {
@@ -159,6 +161,210 @@ func TestReadJSON(t *testing.T) {
 	}
 }
 
+func TestReadJSONArray(t *testing.T) {
+	t.Parallel()
+
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name    string
+		give    string
+		stopAt  int
+		want    []TestSlides
+		wantErr error
+	}{
+		{
+			name: "array of objects",
+			give: `[{"title":"One","type":"all"}, {"title":"Two","type":"some"}]`,
+			want: []TestSlides{{Title: "One", Type: "all"}, {Title: "Two", Type: "some"}},
+		},
+		{
+			name: "empty array",
+			give: `[]`,
+		},
+		{
+			name:    "not an array",
+			give:    `{"title":"One"}`,
+			wantErr: httpx.ErrNotJSONArray,
+		},
+		{
+			name:    "malformed element",
+			give:    `[{"title":"One"}, {"title":]`,
+			want:    []TestSlides{{Title: "One"}},
+			wantErr: httpx.ErrCannotDecodeJSON,
+		},
+		{
+			name:    "callback stops decoding",
+			give:    `[{"title":"One"}, {"title":"Two"}, {"title":"Three"}]`,
+			stopAt:  2,
+			want:    []TestSlides{{Title: "One"}, {Title: "Two"}},
+			wantErr: errStop,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp := &http.Response{
+				Body: io.NopCloser(bytes.NewReader([]byte(tt.give))),
+			}
+
+			var got []TestSlides
+
+			err := httpx.ReadJSONArray(resp, func(slide TestSlides) error {
+				got = append(got, slide)
+
+				if len(got) == tt.stopAt {
+					return errStop
+				}
+
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadJSONArray_Streaming(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		streaming bool
+		wantErr   bool
+	}{
+		{
+			name:      "streaming request outlives timeouts",
+			streaming: true,
+		},
+		{
+			name:    "regular request times out",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+
+				fmt.Fprint(w, "[1,")
+				w.(http.Flusher).Flush()
+
+				time.Sleep(150 * time.Millisecond)
+
+				fmt.Fprint(w, "2]")
+			}))
+			t.Cleanup(server.Close)
+
+			client := httpx.NewClient()
+			client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+			client.Timeout = 50 * time.Millisecond
+			client.RetryPolicy.PerAttemptTimeout = 50 * time.Millisecond
+
+			ctx := context.Background()
+			if tt.streaming {
+				ctx = httpx.WithStreaming(ctx)
+			}
+
+			resp, err := client.Get(ctx, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []int
+
+			err = httpx.ReadJSONArray(resp, func(v int) error {
+				got = append(got, v)
+
+				return nil
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := []int{1, 2}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    []TestSlides
+		wantErr error
+	}{
+		{
+			name: "multiple lines",
+			give: "{\"title\":\"One\"}\n{\"title\":\"Two\"}\n\n{\"title\":\"Three\"}\n",
+			want: []TestSlides{{Title: "One"}, {Title: "Two"}, {Title: "Three"}},
+		},
+		{
+			name: "empty body",
+			give: "",
+		},
+		{
+			name:    "malformed line",
+			give:    "{\"title\":\"One\"}\n{\"title\"\n",
+			want:    []TestSlides{{Title: "One"}},
+			wantErr: httpx.ErrCannotDecodeJSON,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp := &http.Response{
+				Body: io.NopCloser(bytes.NewReader([]byte(tt.give))),
+			}
+
+			var got []TestSlides
+
+			err := httpx.ReadNDJSON(resp, func(slide TestSlides) error {
+				got = append(got, slide)
+
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestWriteJSON(t *testing.T) {
 	t.Parallel()
 
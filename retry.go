@@ -2,9 +2,13 @@ package httpx
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"strconv"
+	"syscall"
 	"time"
 
 	"git.sr.ht/~jamesponddotco/xstd-go/xcrypto/xrand"
@@ -15,17 +19,15 @@ import (
 // jittered delay.
 const _jitterFraction float64 = 0.25
 
+// _headerIdempotencyKey is the header used to make requests with
+// non-idempotent methods safe to retry.
+const _headerIdempotencyKey string = "Idempotency-Key"
+
 // ErrRetryCanceled is returned when the request is canceled while waiting to retry.
 const ErrRetryCanceled xerrors.Error = "retry canceled"
 
 // RetryPolicy defines a policy for retrying HTTP requests.
 type RetryPolicy struct {
-	// retryTimer is a timer used to wait before retrying a request.
-	retryTimer *time.Timer
-
-	// retryableStatusCodeMap is a map of HTTP status codes that should trigger a retry.
-	retryableStatusCodeMap map[int]bool
-
 	// RetryableStatusCodes is a slice of HTTP status codes that should trigger
 	// a retry.
 	//
@@ -42,6 +44,19 @@ type RetryPolicy struct {
 
 	// MaxRetryDelay is the maximum duration to wait before retrying a request.
 	MaxRetryDelay time.Duration
+
+	// PerAttemptTimeout is the maximum duration of a single attempt, including
+	// reading the response body. It applies on top of the deadline of the
+	// request's context, so a slow attempt times out and is retried instead of
+	// using up the whole deadline. It does not apply to streamed responses,
+	// such as event streams and downloads, which are only limited by their
+	// context. Zero means no per-attempt timeout.
+	PerAttemptTimeout time.Duration
+
+	// RetryNonIdempotent specifies whether requests with non-idempotent
+	// methods, such as POST and PATCH, should be retried. Requests with an
+	// Idempotency-Key header are retried regardless of this setting.
+	RetryNonIdempotent bool
 }
 
 // DefaultRetryPolicy returns a RetryPolicy with sensible defaults for retrying HTTP requests.
@@ -57,31 +72,24 @@ func DefaultRetryPolicy() *RetryPolicy {
 		http.StatusLocked,
 	}
 
-	retryableStatusCodeMap := make(map[int]bool, len(retryableStatusCodes))
-	for _, code := range retryableStatusCodes {
-		retryableStatusCodeMap[code] = true
-	}
-
 	return &RetryPolicy{
-		retryTimer:             time.NewTimer(0),
-		retryableStatusCodeMap: retryableStatusCodeMap,
-		RetryableStatusCodes:   retryableStatusCodes,
-		MaxRetries:             4,
-		MinRetryDelay:          1 * time.Second,
-		MaxRetryDelay:          30 * time.Second,
+		RetryableStatusCodes: retryableStatusCodes,
+		MaxRetries:           4,
+		MinRetryDelay:        1 * time.Second,
+		MaxRetryDelay:        30 * time.Second,
 	}
 }
 
 // RetryAfter returns the amount of time to wait before retrying a request
 // based on the "Retry-After" header.
 //
-// If the header is not present, the returned duration is MinRetryDelay with
-// added jitter to prevent thundering herds.
+// If the header is not present or resp is nil, the returned duration is
+// MinRetryDelay with added jitter to prevent thundering herds.
 func (p *RetryPolicy) RetryAfter(resp *http.Response) time.Duration {
 	delay := p.MinRetryDelay
 
-	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
-		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
 			delay = time.Duration(seconds) * time.Second
 		}
 	}
@@ -99,9 +107,61 @@ func (p *RetryPolicy) RetryAfter(resp *http.Response) time.Duration {
 }
 
 // ShouldRetry checks if the response's status code indicates that the request
-// should be retried.
+// should be retried. Whether the request's method allows retries is checked
+// separately by IsRetryable.
 func (p *RetryPolicy) ShouldRetry(resp *http.Response) bool {
-	return p.retryableStatusCodeMap[resp.StatusCode]
+	for _, code := range p.RetryableStatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsRetryable checks if the request is eligible for retries based on its
+// method. Requests with idempotent methods are always eligible, while requests
+// with non-idempotent methods are only eligible if they have an
+// Idempotency-Key header or RetryNonIdempotent is true.
+func (p *RetryPolicy) IsRetryable(req *http.Request) bool {
+	if p.RetryNonIdempotent || isIdempotent(req.Method) {
+		return true
+	}
+
+	return req.Header.Get(_headerIdempotencyKey) != ""
+}
+
+// ShouldRetryError checks if an error returned while sending a request is
+// transient, indicating that the request should be retried.
+//
+// Timeouts, refused or reset connections and connections closed before a
+// response was received are considered transient. Canceled contexts, TLS and
+// certificate failures, and any other errors are not.
+func (*RetryPolicy) ShouldRetryError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+
+	return false
 }
 
 // Wait blocks until the specified request should be retried or the context is
@@ -109,12 +169,16 @@ func (p *RetryPolicy) ShouldRetry(resp *http.Response) bool {
 //
 // If the context is canceled, it returns an error.
 func (p *RetryPolicy) Wait(ctx context.Context, resp *http.Response) error {
-	delay := p.RetryAfter(resp)
+	return p.sleep(ctx, p.RetryAfter(resp))
+}
 
-	p.retryTimer.Reset(delay)
+// sleep blocks for the given delay or until the context is canceled.
+func (*RetryPolicy) sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
 
 	select {
-	case <-p.retryTimer.C:
+	case <-timer.C:
 		return nil
 	case <-ctx.Done():
 		return fmt.Errorf("%w: %w", ErrRetryCanceled, ctx.Err())
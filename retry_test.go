@@ -3,12 +3,16 @@ package httpx_test
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
 )
 
 func TestRetryPolicy_RetryAfter(t *testing.T) {
@@ -197,3 +201,205 @@ func TestRetryPolicy_Wait(t *testing.T) {
 		})
 	}
 }
+
+func TestRetryPolicy_IsRetryable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name               string
+		method             string
+		idempotencyKey     string
+		retryNonIdempotent bool
+		want               bool
+	}{
+		{
+			name:   "GET request",
+			method: http.MethodGet,
+			want:   true,
+		},
+		{
+			name:   "PUT request",
+			method: http.MethodPut,
+			want:   true,
+		},
+		{
+			name:   "POST request",
+			method: http.MethodPost,
+			want:   false,
+		},
+		{
+			name:           "POST request with idempotency key",
+			method:         http.MethodPost,
+			idempotencyKey: "key",
+			want:           true,
+		},
+		{
+			name:               "PATCH request with RetryNonIdempotent",
+			method:             http.MethodPatch,
+			retryNonIdempotent: true,
+			want:               true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			policy := httpx.DefaultRetryPolicy()
+			policy.RetryNonIdempotent = tt.retryNonIdempotent
+
+			req := httptest.NewRequest(tt.method, "https://example.com/", http.NoBody)
+			if tt.idempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", tt.idempotencyKey)
+			}
+
+			if got := policy.IsRetryable(req); got != tt.want {
+				t.Errorf("IsRetryable() = %v, expected %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_IdempotencyKeys(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu     sync.Mutex
+		keys   []string
+		bodies []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		bodies = append(bodies, string(body))
+
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.IdempotencyKeys = true
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy.MinRetryDelay = time.Millisecond
+	client.RetryPolicy.MaxRetryDelay = time.Millisecond
+
+	resp, err := client.Post(context.Background(), server.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(keys) != 2 {
+		t.Fatalf("server received %d requests, want 2", len(keys))
+	}
+
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("got idempotency keys %q, want the same non-empty key", keys)
+	}
+
+	for i, body := range bodies {
+		if body != "payload" {
+			t.Errorf("attempt %d sent body %q, want %q", i+1, body, "payload")
+		}
+	}
+}
+
+func TestClient_PerAttemptTimeout(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		first := calls == 1
+		mu.Unlock()
+
+		if first {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy.MinRetryDelay = time.Millisecond
+	client.RetryPolicy.MaxRetryDelay = time.Millisecond
+	client.RetryPolicy.PerAttemptTimeout = 50 * time.Millisecond
+
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if calls != 2 {
+		t.Errorf("server received %d requests, want 2", calls)
+	}
+}
+
+func TestClient_RetrySkippedNearDeadline(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+
+	resp, err := client.Get(ctx, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Do() took %v, want it to return without waiting to retry", elapsed)
+	}
+}
@@ -0,0 +1,144 @@
+package httpx
+
+import (
+	"sync"
+	"time"
+)
+
+// _retryBudgetBuckets is the number of buckets the retry budget's sliding
+// window is divided into.
+const _retryBudgetBuckets int = 10
+
+// RetryBudget caps the number of retries a Client makes relative to the number
+// of requests it sends, so that retries cannot multiply the load on an
+// upstream that is already struggling.
+//
+// It works like a token bucket: every request deposits Ratio tokens and every
+// retry withdraws one, with tokens expiring once they fall out of the sliding
+// Window. MinRetries tokens are always available, allowing clients with little
+// traffic to retry.
+//
+// A RetryBudget is safe for concurrent use and is meant to be shared by every
+// request made by a Client.
+type RetryBudget struct {
+	// buckets holds the request and retry counts for the sliding window.
+	buckets [_retryBudgetBuckets]retryBudgetBucket
+
+	// Ratio is the maximum ratio of retries to requests within Window. For
+	// example, a ratio of 0.2 allows retries to add at most 20% of extra
+	// traffic.
+	Ratio float64
+
+	// MinRetries is the number of retries allowed within Window regardless of
+	// Ratio.
+	MinRetries int
+
+	// Window is the duration of the sliding window over which requests and
+	// retries are counted.
+	Window time.Duration
+
+	// mu protects buckets.
+	mu sync.Mutex
+}
+
+// retryBudgetBucket holds the counts for a slice of the sliding window.
+type retryBudgetBucket struct {
+	// start is the beginning of the time slice the bucket covers.
+	start time.Time
+
+	// requests is the number of requests made in the time slice.
+	requests int
+
+	// retries is the number of retries made in the time slice.
+	retries int
+}
+
+// DefaultRetryBudget returns a RetryBudget with sensible defaults, allowing
+// retries to account for up to 20% of the requests made over ten seconds.
+func DefaultRetryBudget() *RetryBudget {
+	return &RetryBudget{
+		Ratio:      0.2,
+		MinRetries: 10,
+		Window:     10 * time.Second,
+	}
+}
+
+// Deposit records a new request, adding Ratio tokens to the budget.
+func (b *RetryBudget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bucket(time.Now()).requests++
+}
+
+// Withdraw attempts to take a token from the budget for a retry. It returns
+// false if the budget is exhausted, in which case the retry should not be
+// made.
+func (b *RetryBudget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var (
+		now      = time.Now()
+		requests int
+		retries  int
+	)
+
+	for i := range b.buckets {
+		if b.current(&b.buckets[i], now) {
+			requests += b.buckets[i].requests
+			retries += b.buckets[i].retries
+		}
+	}
+
+	if float64(retries+1) > float64(b.MinRetries)+b.Ratio*float64(requests) {
+		return false
+	}
+
+	b.bucket(now).retries++
+
+	return true
+}
+
+// bucket returns the bucket for the given time, resetting it if it belongs to
+// an expired time slice. The caller must hold b.mu.
+func (b *RetryBudget) bucket(now time.Time) *retryBudgetBucket {
+	var (
+		width  = b.bucketWidth()
+		start  = now.Truncate(width)
+		index  = int(start.UnixNano()/int64(width)) % _retryBudgetBuckets
+		bucket = &b.buckets[index]
+	)
+
+	if !bucket.start.Equal(start) {
+		*bucket = retryBudgetBucket{start: start}
+	}
+
+	return bucket
+}
+
+// current reports whether a bucket falls within the sliding window ending at
+// now.
+func (b *RetryBudget) current(bucket *retryBudgetBucket, now time.Time) bool {
+	return !bucket.start.IsZero() && now.Sub(bucket.start) < b.window()
+}
+
+// bucketWidth returns the duration covered by each bucket.
+func (b *RetryBudget) bucketWidth() time.Duration {
+	width := b.window() / time.Duration(_retryBudgetBuckets)
+	if width <= 0 {
+		return 1
+	}
+
+	return width
+}
+
+// window returns the duration of the sliding window, falling back to the
+// default if none was set.
+func (b *RetryBudget) window() time.Duration {
+	if b.Window <= 0 {
+		return 10 * time.Second
+	}
+
+	return b.Window
+}
@@ -0,0 +1,119 @@
+package httpx_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+func TestRetryBudget_Withdraw(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		ratio      float64
+		minRetries int
+		requests   int
+		want       int
+	}{
+		{
+			name:     "ratio only",
+			ratio:    0.5,
+			requests: 4,
+			want:     2,
+		},
+		{
+			name:       "min retries only",
+			minRetries: 3,
+			want:       3,
+		},
+		{
+			name:       "ratio and min retries",
+			ratio:      0.2,
+			minRetries: 1,
+			requests:   10,
+			want:       3,
+		},
+		{
+			name:     "empty budget",
+			requests: 10,
+			want:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			budget := &httpx.RetryBudget{
+				Ratio:      tt.ratio,
+				MinRetries: tt.minRetries,
+				Window:     time.Minute,
+			}
+
+			for i := 0; i < tt.requests; i++ {
+				budget.Deposit()
+			}
+
+			var got int
+
+			for budget.Withdraw() {
+				got++
+
+				if got > tt.want {
+					break
+				}
+			}
+
+			if got != tt.want {
+				t.Errorf("Withdraw() allowed %d retries, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_RetryBudget(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy.MinRetryDelay = time.Millisecond
+	client.RetryPolicy.MaxRetryDelay = time.Millisecond
+	client.RetryBudget = &httpx.RetryBudget{
+		MinRetries: 1,
+		Window:     time.Minute,
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(context.Background(), server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+		}
+
+		resp.Body.Close()
+	}
+
+	if got := calls.Load(); got != 3 {
+		t.Errorf("server received %d requests, want 3", got)
+	}
+}
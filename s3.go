@@ -0,0 +1,265 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrS3Upload is returned when an S3 multipart upload request fails with an
+// error document, including one sent with a successful status.
+const ErrS3Upload xerrors.Error = "S3 multipart upload failed"
+
+// _mediaTypeXML is the Content-Type of XML request bodies.
+const _mediaTypeXML string = "application/xml"
+
+// S3Protocol is an UploadProtocol implementing S3-style multipart uploads, as
+// supported by Amazon S3 and compatible object stores.
+//
+// Every chunk but the last must be at least as large as the minimum part size
+// of the object store, which is 5 MiB for Amazon S3. Parts whose upload fails
+// are uploaded again in full.
+type S3Protocol struct {
+	// Sign, if not nil, is called to sign every request before it is sent, for
+	// example with AWS Signature Version 4. The request body can be read
+	// through GetBody.
+	Sign func(req *http.Request) error
+
+	// URL is the URL of the object being uploaded.
+	URL string
+}
+
+// Compile-time check to ensure S3Protocol implements the UploadProtocol
+// interface.
+var _ UploadProtocol = (*S3Protocol)(nil)
+
+// NewS3Protocol returns a new S3Protocol uploading the object at the given URL.
+func NewS3Protocol(uri string) *S3Protocol {
+	return &S3Protocol{
+		URL: uri,
+	}
+}
+
+// s3InitiateResult is the response body of a request initiating a multipart
+// upload.
+type s3InitiateResult struct {
+	// UploadID identifies the multipart upload.
+	UploadID string `xml:"UploadId"`
+}
+
+// s3CompleteRequest is the request body of a request completing a multipart
+// upload.
+type s3CompleteRequest struct {
+	// XMLName is the name of the root element.
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+
+	// Parts lists the parts of the upload, in order.
+	Parts []s3CompletePart `xml:"Part"`
+}
+
+// s3CompletePart is a part listed in an s3CompleteRequest.
+type s3CompletePart struct {
+	// ETag is the entity tag returned when the part was uploaded.
+	ETag string `xml:"ETag"`
+
+	// PartNumber is the number of the part.
+	PartNumber int `xml:"PartNumber"`
+}
+
+// s3Result is the root element of a response body, used to tell errors apart
+// from successful results.
+type s3Result struct {
+	// XMLName is the name of the root element, "Error" for error documents.
+	XMLName xml.Name
+
+	// Code is the error code of an error document.
+	Code string `xml:"Code"`
+
+	// Message is the error message of an error document.
+	Message string `xml:"Message"`
+}
+
+// Create initiates a multipart upload and sets the upload's URL and ID.
+func (p *S3Protocol) Create(ctx context.Context, client *Client, upload *Upload) error {
+	query := url.Values{}
+	query.Set("uploads", "")
+
+	resp, err := p.send(ctx, client, http.MethodPost, query, nil)
+	if err != nil {
+		return err
+	}
+
+	var result s3InitiateResult
+
+	if err = decodeS3Result(client, resp, &result); err != nil {
+		return err
+	}
+
+	upload.URL = p.URL
+	upload.ID = result.UploadID
+
+	return nil
+}
+
+// Offset returns the end of the last part uploaded, as parts whose upload
+// failed are uploaded again in full.
+func (*S3Protocol) Offset(_ context.Context, _ *Client, upload *Upload) (int64, error) {
+	var offset int64
+
+	for _, part := range upload.Parts {
+		offset += part.Size
+	}
+
+	return offset, nil
+}
+
+// ChunkRequest returns a PUT request uploading chunk as the next part of the
+// upload.
+func (p *S3Protocol) ChunkRequest(ctx context.Context, upload *Upload, chunk []byte, _ bool) (*http.Request, error) {
+	query := url.Values{}
+	query.Set("partNumber", strconv.Itoa(len(upload.Parts)+1))
+	query.Set("uploadId", upload.ID)
+
+	return p.newRequest(ctx, http.MethodPut, query, chunk)
+}
+
+// ChunkDone records the part and the ETag returned by the server.
+func (*S3Protocol) ChunkDone(upload *Upload, resp *http.Response, chunk []byte) error {
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return fmt.Errorf("%w: part %d has no ETag", ErrS3Upload, len(upload.Parts)+1)
+	}
+
+	upload.Parts = append(upload.Parts, UploadPart{
+		ETag:   etag,
+		Number: len(upload.Parts) + 1,
+		Size:   int64(len(chunk)),
+	})
+
+	return nil
+}
+
+// Complete completes the multipart upload with the parts uploaded.
+func (p *S3Protocol) Complete(ctx context.Context, client *Client, upload *Upload) error {
+	complete := s3CompleteRequest{
+		Parts: make([]s3CompletePart, 0, len(upload.Parts)),
+	}
+
+	for _, part := range upload.Parts {
+		complete.Parts = append(complete.Parts, s3CompletePart{
+			ETag:       part.ETag,
+			PartNumber: part.Number,
+		})
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	query := url.Values{}
+	query.Set("uploadId", upload.ID)
+
+	resp, err := p.send(ctx, client, http.MethodPost, query, body)
+	if err != nil {
+		return err
+	}
+
+	return decodeS3Result(client, resp, nil)
+}
+
+// newRequest returns a new signed request to the object URL with the given
+// query and body.
+func (p *S3Protocol) newRequest(ctx context.Context, method string, query url.Values, body []byte) (*http.Request, error) {
+	uri, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	// S3 expects flags such as "uploads" without an equals sign.
+	uri.RawQuery = query.Encode()
+	if query.Has("uploads") {
+		uri.RawQuery = "uploads"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, uri.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if method == http.MethodPost && len(body) > 0 {
+		req.Header.Set("Content-Type", _mediaTypeXML)
+	}
+
+	if p.Sign != nil {
+		if err = p.Sign(req); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	return req, nil
+}
+
+// send sends a request to the object URL and returns its response if its
+// status is successful.
+func (p *S3Protocol) send(ctx context.Context, client *Client, method string, query url.Values, body []byte) (*http.Response, error) {
+	req, err := p.newRequest(ctx, method, query, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer client.discardResponse(resp)
+
+		return nil, uploadStatusError(resp)
+	}
+
+	return resp, nil
+}
+
+// decodeS3Result decodes the XML body of a response into v, if not nil, and
+// closes it. It returns an error if the body is an S3 error document.
+func decodeS3Result(client *Client, resp *http.Response, v any) error {
+	defer client.discardResponse(resp)
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrS3Upload, err)
+	}
+
+	if v == nil && len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	var result s3Result
+
+	if err = xml.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("%w: %w", ErrS3Upload, err)
+	}
+
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("%w: %s: %s", ErrS3Upload, result.Code, result.Message)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	if err = xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrS3Upload, err)
+	}
+
+	return nil
+}
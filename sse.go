@@ -0,0 +1,357 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrNotEventStream is returned when a server responds to an event stream
+// request with a Content-Type other than text/event-stream.
+const ErrNotEventStream xerrors.Error = "response is not an event stream"
+
+// errNoContent is returned when a server responds with 204 No Content,
+// signaling that the client should stop reconnecting.
+const errNoContent xerrors.Error = "event stream ended by server"
+
+const (
+	// _mediaTypeEventStream is the Content-Type of Server-Sent Events streams.
+	_mediaTypeEventStream string = "text/event-stream"
+
+	// _headerLastEventID is the header used to resume an event stream.
+	_headerLastEventID string = "Last-Event-ID"
+
+	// _defaultEventType is the type of events without an event field.
+	_defaultEventType string = "message"
+)
+
+// Event is a single Server-Sent Event.
+type Event struct {
+	// ID is the last event ID set by the stream at the time the event was
+	// dispatched.
+	ID string
+
+	// Type is the event type, "message" if the event had no event field.
+	Type string
+
+	// Data is the event data, with the lines of multi-line data joined by
+	// newlines.
+	Data string
+
+	// Retry is the reconnection delay set by the event, or zero if the event
+	// didn't set one.
+	Retry time.Duration
+}
+
+// EventStream reads Server-Sent Events from a response, reconnecting with the
+// Last-Event-ID header when the connection drops.
+//
+// Reconnections wait for the delay set by the server through the retry field,
+// or RetryPolicy.RetryAfter if the server did not set one, and are then
+// retried by Client.Do like any other request. The stream stops reconnecting
+// if the Client has no RetryPolicy, if reconnecting fails, or if the server
+// responds with 204 No Content.
+//
+// An EventStream is not safe for concurrent use, except for Close.
+type EventStream struct {
+	// parentErr returns the error of the context the stream was opened with.
+	parentErr func() error
+
+	// cancel cancels the context of req.
+	cancel context.CancelFunc
+
+	// client is the client used to connect.
+	client *Client
+
+	// req is the request used to connect. Its context is canceled by Close.
+	req *http.Request
+
+	// resp is the current response.
+	resp *http.Response
+
+	// reader reads from the current response body.
+	reader *bufio.Reader
+
+	// err is the error that stopped the stream, if any.
+	err error
+
+	// event is the last event read.
+	event Event
+
+	// lastEventID is the last event ID set by the stream.
+	lastEventID string
+
+	// retry is the reconnection delay set by the server.
+	retry time.Duration
+
+	// done specifies whether the stream ended.
+	done bool
+
+	// mu protects resp.
+	mu sync.Mutex
+}
+
+// Events sends the request and returns an EventStream reading Server-Sent
+// Events from its response. It returns an error if the first connection fails.
+//
+// The client's timeout and cache do not apply to event streams; use the
+// context to limit how long the stream stays open.
+func (c *Client) Events(ctx context.Context, req *http.Request) (*EventStream, error) {
+	streamCtx, cancel := context.WithCancel(WithStreaming(ctx))
+
+	stream := &EventStream{
+		parentErr:   ctx.Err,
+		cancel:      cancel,
+		client:      c,
+		req:         req.WithContext(streamCtx),
+		lastEventID: req.Header.Get(_headerLastEventID),
+	}
+
+	if err := stream.connect(); err != nil {
+		cancel()
+
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// Next reads the next event, reconnecting if needed, and reports whether one
+// was read. Once Next returns false, Err reports the error that stopped the
+// stream, if any.
+func (s *EventStream) Next() bool {
+	for !s.done {
+		if s.reader == nil {
+			if err := s.reconnect(); err != nil {
+				s.stop(err)
+
+				return false
+			}
+		}
+
+		event, err := s.readEvent()
+		if err == nil {
+			s.event = event
+
+			return true
+		}
+
+		if closeErr := s.closeBody(); closeErr != nil {
+			s.client.debugf("[DEBUG] Failed to close event stream: %v", closeErr)
+		}
+
+		s.reader = nil
+
+		if s.client.RetryPolicy == nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+
+			s.stop(err)
+		}
+	}
+
+	return false
+}
+
+// Event returns the last event read by Next.
+func (s *EventStream) Event() Event {
+	return s.event
+}
+
+// LastEventID returns the last event ID set by the stream, which is sent in
+// the Last-Event-ID header when reconnecting.
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Err returns the error that stopped the stream, if any. It returns nil if the
+// stream ended normally or was closed.
+func (s *EventStream) Err() error {
+	return s.err
+}
+
+// Close closes the stream. Any blocked call to Next returns false.
+func (s *EventStream) Close() error {
+	s.cancel()
+
+	return s.closeBody()
+}
+
+// connect sends the request and prepares the response for reading.
+func (s *EventStream) connect() error {
+	ctx := s.req.Context()
+
+	req := s.req.Clone(ctx)
+	req.Header.Set("Accept", _mediaTypeEventStream)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	if s.lastEventID != "" {
+		req.Header.Set(_headerLastEventID, s.lastEventID)
+	}
+
+	resp, err := s.client.Do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if resp.StatusCode == http.StatusNoContent {
+		s.client.discardResponse(resp)
+
+		return errNoContent
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		s.client.discardResponse(resp)
+
+		return &Error{
+			URL:        req.URL,
+			Method:     req.Method,
+			Message:    "unexpected status for event stream",
+			StatusText: http.StatusText(resp.StatusCode),
+			StatusCode: resp.StatusCode,
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != _mediaTypeEventStream {
+		s.client.discardResponse(resp)
+
+		return fmt.Errorf("%w: %s", ErrNotEventStream, resp.Header.Get("Content-Type"))
+	}
+
+	s.mu.Lock()
+	s.resp = resp
+	s.mu.Unlock()
+
+	s.reader = bufio.NewReader(resp.Body)
+
+	return nil
+}
+
+// reconnect waits for the reconnection delay and connects again.
+func (s *EventStream) reconnect() error {
+	delay := s.retry
+	if delay == 0 {
+		delay = s.client.RetryPolicy.RetryAfter(nil)
+	}
+
+	if err := s.client.RetryPolicy.sleep(s.req.Context(), delay); err != nil {
+		return err
+	}
+
+	return s.connect()
+}
+
+// stop ends the stream with the given error. Errors caused by the stream being
+// closed or ended by the server are not reported.
+func (s *EventStream) stop(err error) {
+	closed := s.req.Context().Err() != nil
+
+	s.done = true
+	s.cancel()
+
+	if errors.Is(err, errNoContent) || (err != nil && closed) {
+		err = nil
+
+		if parent := s.parentErr(); parent != nil {
+			err = fmt.Errorf("%w", parent)
+		}
+	}
+
+	s.err = err
+}
+
+// closeBody closes the current response body, if any.
+func (s *EventStream) closeBody() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.resp == nil {
+		return nil
+	}
+
+	resp := s.resp
+	s.resp = nil
+
+	if err := resp.Body.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotCloseResponse, err)
+	}
+
+	return nil
+}
+
+// readEvent reads lines until a complete event is dispatched, as described by
+// the Server-Sent Events specification.
+func (s *EventStream) readEvent() (Event, error) {
+	var (
+		data      strings.Builder
+		eventType string
+		retry     time.Duration
+		hasData   bool
+	)
+
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return Event{}, fmt.Errorf("%w", err)
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if !hasData {
+				eventType = ""
+
+				continue
+			}
+
+			if eventType == "" {
+				eventType = _defaultEventType
+			}
+
+			return Event{
+				ID:    s.lastEventID,
+				Type:  eventType,
+				Data:  strings.TrimSuffix(data.String(), "\n"),
+				Retry: retry,
+			}, nil
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 32); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+				s.retry = retry
+			}
+		}
+	}
+}
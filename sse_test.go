@@ -0,0 +1,245 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+func TestClient_Events(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+
+		fmt.Fprint(w, ": comment\n\n")
+		fmt.Fprint(w, "data: first\n\n")
+		fmt.Fprint(w, "event: update\r\nid: 42\r\ndata: multi\r\ndata:line\r\n\r\n")
+		fmt.Fprint(w, "retry: 1500\nid\ndata: {\"ok\":true}\n\n")
+		fmt.Fprint(w, "data: incomplete")
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy = nil
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.Events(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	var got []httpx.Event
+
+	for stream.Next() {
+		got = append(got, stream.Event())
+	}
+
+	if err = stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []httpx.Event{
+		{Type: "message", Data: "first"},
+		{ID: "42", Type: "update", Data: "multi\nline"},
+		{Type: "message", Data: `{"ok":true}`, Retry: 1500 * time.Millisecond},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %+v, want %+v", got, want)
+	}
+}
+
+func TestClient_Events_Reconnect(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu           sync.Mutex
+		lastEventIDs []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		connection := len(lastEventIDs)
+		mu.Unlock()
+
+		switch connection {
+		case 1:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "retry: 1\nid: 1\ndata: one\n\n")
+		case 2:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "id: 2\ndata: two\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy.MinRetryDelay = time.Millisecond
+	client.RetryPolicy.MaxRetryDelay = time.Millisecond
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.Events(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	var got []string
+
+	for stream.Next() {
+		got = append(got, stream.Event().Data)
+	}
+
+	if err = stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"one", "two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got data %q, want %q", got, want)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if want := []string{"", "1", "2"}; !reflect.DeepEqual(lastEventIDs, want) {
+		t.Errorf("server received Last-Event-ID headers %q, want %q", lastEventIDs, want)
+	}
+
+	if got := stream.LastEventID(); got != "2" {
+		t.Errorf("got last event ID %q, want %q", got, "2")
+	}
+}
+
+func TestClient_Events_NotEventStream(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{}`)
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Events(context.Background(), req)
+	if !errors.Is(err, httpx.ErrNotEventStream) {
+		t.Errorf("got error %v, want %v", err, httpx.ErrNotEventStream)
+	}
+}
+
+func TestEventStream_Close(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.Events(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !stream.Next() {
+		t.Fatalf("expected an event, got error %v", stream.Err())
+	}
+
+	time.AfterFunc(10*time.Millisecond, func() {
+		stream.Close()
+	})
+
+	if stream.Next() {
+		t.Fatal("expected no more events after Close")
+	}
+
+	if err = stream.Err(); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}
+
+func TestClient_Events_PerAttemptTimeout(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		fmt.Fprint(w, "id: 1\ndata: first\n\n")
+		w.(http.Flusher).Flush()
+
+		time.Sleep(100 * time.Millisecond)
+
+		fmt.Fprint(w, "id: 2\ndata: second\n\n")
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClient()
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy.PerAttemptTimeout = 20 * time.Millisecond
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.Events(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	var got []string
+
+	for len(got) < 2 && stream.Next() {
+		got = append(got, stream.Event().Data)
+	}
+
+	if err = stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"first", "second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %q, want %q", got, want)
+	}
+}
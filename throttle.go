@@ -0,0 +1,295 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// _throttleIdleTimeout is how long the limiters of a host are kept once no
+// body uses them. It must be longer than the one second it takes a limiter to
+// refill its burst, so that evicting it is indistinguishable from keeping it.
+const _throttleIdleTimeout time.Duration = time.Minute
+
+// Throttle limits the bandwidth used by request and response bodies, in bytes
+// per second, across all hosts and for each host. Request bodies count as
+// written bytes and response bodies as read bytes, including bodies discarded
+// before retries.
+//
+// Limits are enforced while bodies are read, so a throttled response body only
+// slows down once the caller starts reading it. Responses served from the
+// client's cache are not throttled.
+//
+// The limiters of a host are evicted once none of its bodies have been open for
+// a minute, so that long-running clients talking to many hosts do not
+// accumulate them.
+//
+// The zero value only applies the per-host limits; use NewThrottle to also
+// limit bodies across all hosts.
+type Throttle struct {
+	// read limits response bodies across all hosts.
+	read *rate.Limiter
+
+	// write limits request bodies across all hosts.
+	write *rate.Limiter
+
+	// hosts holds the limiters for each host.
+	hosts map[string]*hostThrottle
+
+	// swept is when idle hosts were last evicted.
+	swept time.Time
+
+	// HostReadLimit is the maximum rate, in bytes per second, at which the
+	// response bodies of a single host are read. Zero means no limit.
+	HostReadLimit rate.Limit
+
+	// HostWriteLimit is the maximum rate, in bytes per second, at which the
+	// request bodies of a single host are sent. Zero means no limit.
+	HostWriteLimit rate.Limit
+
+	// mu protects hosts, swept and the state of every hostThrottle.
+	mu sync.Mutex
+}
+
+// hostThrottle holds the limiters for a single host.
+type hostThrottle struct {
+	// read limits the response bodies of the host.
+	read *rate.Limiter
+
+	// write limits the request bodies of the host.
+	write *rate.Limiter
+
+	// idleSince is when the last body using the limiters was closed.
+	idleSince time.Time
+
+	// bodies is the number of open bodies using the limiters.
+	bodies int
+}
+
+// NewThrottle returns a new Throttle limiting response bodies to readLimit and
+// request bodies to writeLimit bytes per second across all hosts. Zero means no
+// limit.
+func NewThrottle(readLimit, writeLimit rate.Limit) *Throttle {
+	return &Throttle{
+		read:  newByteLimiter(readLimit),
+		write: newByteLimiter(writeLimit),
+	}
+}
+
+// throttleRequest throttles the body of the request, including copies of it
+// made to retry the request.
+func (c *Client) throttleRequest(req *http.Request) {
+	if c.Throttle == nil || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	var (
+		ctx      = req.Context()
+		host     = requestHost(req)
+		throttle = c.Throttle
+	)
+
+	req.Body = throttle.body(ctx, host, req.Body, true)
+
+	if req.GetBody == nil {
+		return
+	}
+
+	getBody := req.GetBody
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		body, err := getBody()
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		return throttle.body(ctx, host, body, true), nil
+	}
+}
+
+// throttleResponse throttles the body of the response to the request.
+func (c *Client) throttleResponse(req *http.Request, resp *http.Response) {
+	if c.Throttle == nil {
+		return
+	}
+
+	resp.Body = c.Throttle.body(req.Context(), requestHost(req), resp.Body, false)
+}
+
+// body returns body throttled by the limiters applying to the request or
+// response bodies of the given host, or body itself if no limiter applies.
+func (t *Throttle) body(ctx context.Context, host string, body io.ReadCloser, write bool) io.ReadCloser {
+	limiters, release := t.acquire(host, write)
+	if len(limiters) == 0 {
+		release()
+
+		return body
+	}
+
+	return newThrottledBody(ctx, body, limiters, release)
+}
+
+// acquire returns the limiters applying to the request or response bodies of
+// the given host, and a function to call once the body using them is closed.
+func (t *Throttle) acquire(host string, write bool) ([]*rate.Limiter, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sweep()
+
+	if t.hosts == nil {
+		t.hosts = make(map[string]*hostThrottle)
+	}
+
+	h, ok := t.hosts[host]
+	if !ok {
+		h = &hostThrottle{
+			read:  newByteLimiter(t.HostReadLimit),
+			write: newByteLimiter(t.HostWriteLimit),
+		}
+
+		t.hosts[host] = h
+	}
+
+	h.bodies++
+
+	var once sync.Once
+
+	release := func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			h.bodies--
+
+			if h.bodies == 0 {
+				h.idleSince = time.Now()
+			}
+		})
+	}
+
+	candidates := [2]*rate.Limiter{t.read, h.read}
+	if write {
+		candidates = [2]*rate.Limiter{t.write, h.write}
+	}
+
+	limiters := make([]*rate.Limiter, 0, len(candidates))
+
+	for _, limiter := range candidates {
+		if limiter != nil {
+			limiters = append(limiters, limiter)
+		}
+	}
+
+	return limiters, release
+}
+
+// sweep evicts the hosts whose limiters have not been used by any body for
+// _throttleIdleTimeout, at most once every _throttleIdleTimeout. The caller
+// must hold t.mu.
+func (t *Throttle) sweep() {
+	now := time.Now()
+
+	if now.Sub(t.swept) < _throttleIdleTimeout {
+		return
+	}
+
+	t.swept = now
+
+	for host, h := range t.hosts {
+		if h.bodies == 0 && now.Sub(h.idleSince) >= _throttleIdleTimeout {
+			delete(t.hosts, host)
+		}
+	}
+}
+
+// newByteLimiter returns a limiter allowing limit bytes per second, with a
+// burst of one second worth of bytes, or nil if limit is zero or infinite.
+func newByteLimiter(limit rate.Limit) *rate.Limiter {
+	if limit <= 0 || limit == rate.Inf {
+		return nil
+	}
+
+	burst := int(limit)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return rate.NewLimiter(limit, burst)
+}
+
+// throttledBody is an io.ReadCloser that waits on limiters for every byte read
+// from the underlying body.
+type throttledBody struct {
+	io.ReadCloser
+
+	// wait waits on every limiter for n bytes.
+	wait func(n int) error
+
+	// release is called once the body is closed.
+	release func()
+
+	// burst is the largest number of bytes read at once.
+	burst int
+}
+
+// newThrottledBody returns a new throttledBody reading from body, waiting on
+// the limiters until ctx is done and calling release once closed.
+func newThrottledBody(ctx context.Context, body io.ReadCloser, limiters []*rate.Limiter, release func()) *throttledBody {
+	burst := limiters[0].Burst()
+
+	for _, limiter := range limiters[1:] {
+		if limiter.Burst() < burst {
+			burst = limiter.Burst()
+		}
+	}
+
+	return &throttledBody{
+		ReadCloser: body,
+		release:    release,
+		burst:      burst,
+		wait: func(n int) error {
+			for _, limiter := range limiters {
+				if err := limiter.WaitN(ctx, n); err != nil {
+					return fmt.Errorf("%w", err)
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// Read reads at most one burst from the underlying body and waits until the
+// limiters allow the bytes read.
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > b.burst {
+		p = p[:b.burst]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+
+	if n > 0 {
+		if waitErr := b.wait(n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err //nolint:wrapcheck // Callers expect io.EOF as is.
+}
+
+// Close closes the underlying body and releases the limiters.
+func (b *throttledBody) Close() error {
+	defer b.release()
+
+	if err := b.ReadCloser.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
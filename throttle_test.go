@@ -0,0 +1,160 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+func TestClient_Throttle(t *testing.T) {
+	t.Parallel()
+
+	// With a burst of one second worth of bytes, transferring 1.5 seconds
+	// worth of bytes takes at least half a second.
+	const (
+		limit   = 100_000
+		size    = 150_000
+		minTime = 400 * time.Millisecond
+	)
+
+	tests := []struct {
+		name     string
+		throttle func() *httpx.Throttle
+		upload   bool
+	}{
+		{
+			name: "client read limit",
+			throttle: func() *httpx.Throttle {
+				return httpx.NewThrottle(limit, 0)
+			},
+		},
+		{
+			name: "host read limit",
+			throttle: func() *httpx.Throttle {
+				return &httpx.Throttle{HostReadLimit: limit}
+			},
+		},
+		{
+			name: "client write limit",
+			throttle: func() *httpx.Throttle {
+				return httpx.NewThrottle(0, limit)
+			},
+			upload: true,
+		},
+		{
+			name: "host write limit",
+			throttle: func() *httpx.Throttle {
+				throttle := httpx.NewThrottle(0, 0)
+				throttle.HostWriteLimit = limit
+
+				return throttle
+			},
+			upload: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			content := bytes.Repeat([]byte("z"), size)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+				}
+
+				if r.Method == http.MethodPost {
+					if !bytes.Equal(body, content) {
+						t.Errorf("got request body of %d bytes, want %d", len(body), size)
+					}
+
+					return
+				}
+
+				w.Write(content)
+			}))
+			t.Cleanup(server.Close)
+
+			client := httpx.NewClient()
+			client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+			client.Throttle = tt.throttle()
+
+			start := time.Now()
+
+			var (
+				resp *http.Response
+				err  error
+			)
+
+			if tt.upload {
+				resp, err = client.Post(context.Background(), server.URL, "text/plain", bytes.NewReader(content))
+			} else {
+				resp, err = client.Get(context.Background(), server.URL)
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp.Body.Close()
+
+			if !tt.upload && !bytes.Equal(body, content) {
+				t.Errorf("got response body of %d bytes, want %d", len(body), size)
+			}
+
+			if elapsed := time.Since(start); elapsed < minTime {
+				t.Errorf("transfer took %s, want at least %s", elapsed, minTime)
+			}
+		})
+	}
+}
+
+func TestClient_ThrottleCacheHitClosesBody(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("cached"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClientWithCache(nil)
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.Throttle = &httpx.Throttle{HostWriteLimit: 1_000}
+
+	for i := 0; i < 2; i++ {
+		body := &closeTracker{Reader: strings.NewReader("payload")}
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Do(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+
+		if !body.closed.Load() {
+			t.Errorf("request %d: body was not closed", i+1)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package httpx
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrNoCertificates is returned when PEM data contains no certificates.
+	ErrNoCertificates xerrors.Error = "no certificates found in PEM data"
+
+	// ErrCannotLoadCertificate is returned when a client certificate or its
+	// key cannot be loaded.
+	ErrCannotLoadCertificate xerrors.Error = "cannot load client certificate"
+
+	// ErrPinMismatch is returned when none of the certificates presented by a
+	// server match the public keys pinned for its host.
+	ErrPinMismatch xerrors.Error = "certificate does not match any pinned public key"
+)
+
+// TLSOptions defines additional TLS settings, such as client certificates for
+// mutual TLS, private certificate authorities and public key pinning, applied
+// on top of DefaultTLSConfig.
+type TLSOptions struct {
+	// Pins maps hosts to the base64-encoded SHA-256 hashes of the public keys
+	// their verified certificate chains must contain, as returned by SPKIHash.
+	// Hosts are matched case-insensitively and hosts without pins are not
+	// checked. If certificate verification is disabled with
+	// InsecureSkipVerify, only the server's own certificate is checked.
+	Pins map[string][]string
+
+	// OnReloadError, if not nil, is called when reloading the client
+	// certificate fails, such as when the files are read halfway through a
+	// rotation. The previously loaded certificate keeps being used until the
+	// files are loaded successfully.
+	OnReloadError func(err error)
+
+	// CertFile and KeyFile are the paths to a PEM-encoded client certificate
+	// and its private key, used for mutual TLS.
+	CertFile string
+	KeyFile  string
+
+	// RootCAFiles are paths to PEM-encoded certificate authorities to trust in
+	// addition to the system's.
+	RootCAFiles []string
+
+	// RootCAs are PEM-encoded certificate authorities to trust in addition to
+	// the system's.
+	RootCAs [][]byte
+
+	// ReloadInterval is how often the client certificate files are checked
+	// for changes, allowing certificates to be rotated without restarting.
+	// Zero disables reloading.
+	ReloadInterval time.Duration
+
+	// ExcludeSystemRoots specifies whether the system's certificate
+	// authorities should not be trusted, leaving only RootCAFiles and RootCAs.
+	ExcludeSystemRoots bool
+}
+
+// Config builds a [*tls.Config] from DefaultTLSConfig and the options.
+//
+// [*tls.Config]: https://godocs.io/crypto/tls#Config
+func (o *TLSOptions) Config() (*tls.Config, error) {
+	config := DefaultTLSConfig()
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		reloader, err := newCertReloader(o.CertFile, o.KeyFile, o.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+
+		reloader.onError = o.OnReloadError
+
+		config.GetClientCertificate = reloader.getClientCertificate
+	}
+
+	if len(o.RootCAFiles) > 0 || len(o.RootCAs) > 0 || o.ExcludeSystemRoots {
+		pool, err := o.rootCAs()
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = pool
+	}
+
+	if len(o.Pins) > 0 {
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return o.verifyPins(state, config.InsecureSkipVerify)
+		}
+	}
+
+	return config, nil
+}
+
+// Apply builds a [*tls.Config] from the options and sets it as the given
+// transport's TLS configuration.
+//
+// [*tls.Config]: https://godocs.io/crypto/tls#Config
+func (o *TLSOptions) Apply(t *http.Transport) error {
+	config, err := o.Config()
+	if err != nil {
+		return err
+	}
+
+	t.TLSClientConfig = config
+
+	return nil
+}
+
+// SPKIHash returns the base64-encoded SHA-256 hash of the certificate's
+// SubjectPublicKeyInfo, suitable for use in TLSOptions.Pins.
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// rootCAs returns the certificate pool built from the options.
+func (o *TLSOptions) rootCAs() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+
+	if !o.ExcludeSystemRoots {
+		system, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		pool = system
+	}
+
+	pems := make([][]byte, 0, len(o.RootCAFiles)+len(o.RootCAs))
+
+	for _, file := range o.RootCAFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		pems = append(pems, data)
+	}
+
+	pems = append(pems, o.RootCAs...)
+
+	for _, data := range pems {
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, ErrNoCertificates
+		}
+	}
+
+	return pool, nil
+}
+
+// verifyPins checks that the server's verified certificate chains contain a
+// pinned public key for its host. The certificates presented by the server
+// are not trusted on their own, as anyone can append a pinned certificate to a
+// valid chain, so when verification is skipped only the server's own
+// certificate is checked.
+func (o *TLSOptions) verifyPins(state tls.ConnectionState, insecure bool) error {
+	var pins []string
+
+	for host, hostPins := range o.Pins {
+		if strings.EqualFold(host, state.ServerName) || matchesIPHost(host, state) {
+			pins = append(pins, hostPins...)
+		}
+	}
+
+	if len(pins) == 0 {
+		return nil
+	}
+
+	chains := state.VerifiedChains
+	if len(chains) == 0 && insecure && len(state.PeerCertificates) > 0 {
+		chains = [][]*x509.Certificate{state.PeerCertificates[:1]}
+	}
+
+	for _, chain := range chains {
+		for _, cert := range chain {
+			hash := SPKIHash(cert)
+
+			for _, pin := range pins {
+				if hash == pin {
+					return nil
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrPinMismatch, state.ServerName)
+}
+
+// matchesIPHost reports whether host is an IP address the server's certificate
+// is valid for. Servers dialed by IP address have no server name, so their pins
+// are matched against the certificate instead.
+func matchesIPHost(host string, state tls.ConnectionState) bool {
+	if state.ServerName != "" || len(state.PeerCertificates) == 0 || net.ParseIP(host) == nil {
+		return false
+	}
+
+	return state.PeerCertificates[0].VerifyHostname(host) == nil
+}
+
+// certReloader loads a client certificate from disk, reloading it when the
+// files change.
+type certReloader struct {
+	// cert is the currently loaded certificate.
+	cert *tls.Certificate
+
+	// onError is called when reloading the certificate fails.
+	onError func(err error)
+
+	// checked is when the files were last checked for changes.
+	checked time.Time
+
+	// modTime is the most recent modification time of the files when the
+	// certificate was loaded.
+	modTime time.Time
+
+	// certFile and keyFile are the paths to the certificate and key.
+	certFile string
+	keyFile  string
+
+	// interval is how often the files are checked for changes.
+	interval time.Duration
+
+	// mu protects cert, checked and modTime.
+	mu sync.Mutex
+}
+
+// newCertReloader returns a new certReloader with the certificate loaded.
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	reloader := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+	}
+
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// getClientCertificate implements tls.Config.GetClientCertificate, reloading
+// the certificate if the files changed since it was last loaded. If reloading
+// fails, the previously loaded certificate is returned and reloading is tried
+// again after the interval.
+func (r *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.interval > 0 && time.Since(r.checked) >= r.interval {
+		r.checked = time.Now()
+
+		if modTime, err := r.latestModTime(); err == nil && !modTime.Equal(r.modTime) {
+			if err = r.loadLocked(); err != nil && r.onError != nil {
+				r.onError(err)
+			}
+		}
+	}
+
+	return r.cert, nil
+}
+
+// load loads the certificate from disk.
+func (r *certReloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.loadLocked()
+}
+
+// loadLocked loads the certificate from disk. The caller must hold r.mu.
+func (r *certReloader) loadLocked() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotLoadCertificate, err)
+	}
+
+	certPEM, err := os.ReadFile(r.certFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotLoadCertificate, err)
+	}
+
+	keyPEM, err := os.ReadFile(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotLoadCertificate, err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCannotLoadCertificate, err)
+	}
+
+	r.cert = &cert
+	r.modTime = modTime
+	r.checked = time.Now()
+
+	return nil
+}
+
+// latestModTime returns the most recent modification time of the certificate
+// and key files.
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w", err)
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
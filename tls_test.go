@@ -0,0 +1,378 @@
+package httpx_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+)
+
+func TestTLSOptions(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		w.Header().Set("X-Client-Serial", r.TLS.PeerCertificates[0].SerialNumber.String())
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	var (
+		dir        = t.TempDir()
+		rootCAFile = filepath.Join(dir, "ca.pem")
+		certFile   = filepath.Join(dir, "client.pem")
+		keyFile    = filepath.Join(dir, "client.key")
+		serverCert = server.Certificate()
+	)
+
+	err := os.WriteFile(rootCAFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverCert.Raw}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeClientCert(t, certFile, keyFile, 1)
+
+	tests := []struct {
+		name    string
+		pins    []string
+		wantErr error
+	}{
+		{
+			name: "without pins",
+		},
+		{
+			name: "with matching pin",
+			pins: []string{httpx.SPKIHash(serverCert)},
+		},
+		{
+			name:    "with mismatched pin",
+			pins:    []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+			wantErr: httpx.ErrPinMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := &httpx.TLSOptions{
+				CertFile:           certFile,
+				KeyFile:            keyFile,
+				RootCAFiles:        []string{rootCAFile},
+				ExcludeSystemRoots: true,
+			}
+
+			if tt.pins != nil {
+				opts.Pins = map[string][]string{"127.0.0.1": tt.pins}
+			}
+
+			transport := httpx.DefaultTransport()
+			if err := opts.Apply(transport); err != nil {
+				t.Fatal(err)
+			}
+
+			client := httpx.NewClient()
+			client.Transport = transport
+			client.RetryPolicy = nil
+
+			resp, err := client.Get(context.Background(), server.URL)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestTLSOptions_PinAppendedToChain(t *testing.T) {
+	t.Parallel()
+
+	// httptest servers share a certificate, so one is started only to borrow
+	// its key pair.
+	donor := httptest.NewTLSServer(http.NotFoundHandler())
+	donor.Close()
+
+	var (
+		leaf   = donor.TLS.Certificates[0]
+		pinned = newCertDER(t)
+		chain  = append([][]byte{}, leaf.Certificate...)
+	)
+
+	pinnedCert, err := x509.ParseCertificate(pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: append(chain, pinned),
+			PrivateKey:  leaf.PrivateKey,
+		}},
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name     string
+		pin      string
+		insecure bool
+		wantErr  error
+	}{
+		{
+			name:    "appended pin rejected",
+			pin:     httpx.SPKIHash(pinnedCert),
+			wantErr: httpx.ErrPinMismatch,
+		},
+		{
+			name:     "appended pin rejected without verification",
+			pin:      httpx.SPKIHash(pinnedCert),
+			insecure: true,
+			wantErr:  httpx.ErrPinMismatch,
+		},
+		{
+			name:     "leaf pin accepted without verification",
+			pin:      httpx.SPKIHash(server.Certificate()),
+			insecure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := &httpx.TLSOptions{
+				RootCAs:            [][]byte{pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})},
+				ExcludeSystemRoots: true,
+				Pins:               map[string][]string{"127.0.0.1": {tt.pin}},
+			}
+
+			config, err := opts.Config()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config.InsecureSkipVerify = tt.insecure
+
+			transport := httpx.DefaultTransport()
+			transport.TLSClientConfig = config
+
+			client := httpx.NewClient()
+			client.Transport = transport
+			client.RetryPolicy = nil
+
+			resp, err := client.Get(context.Background(), server.URL)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestTLSOptions_ReloadInterval(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Client-Serial", r.TLS.PeerCertificates[0].SerialNumber.String())
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	var (
+		dir      = t.TempDir()
+		certFile = filepath.Join(dir, "client.pem")
+		keyFile  = filepath.Join(dir, "client.key")
+	)
+
+	writeClientCert(t, certFile, keyFile, 1)
+
+	var reloadFailed atomic.Bool
+
+	opts := &httpx.TLSOptions{
+		OnReloadError: func(err error) {
+			if errors.Is(err, httpx.ErrCannotLoadCertificate) {
+				reloadFailed.Store(true)
+			}
+		},
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		RootCAs:        [][]byte{pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})},
+		ReloadInterval: time.Nanosecond,
+	}
+
+	transport := httpx.DefaultTransport()
+	transport.DisableKeepAlives = true
+
+	if err := opts.Apply(transport); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resumed sessions skip the client certificate exchange.
+	transport.TLSClientConfig.ClientSessionCache = nil
+
+	client := httpx.NewClient()
+	client.Transport = transport
+	client.RetryPolicy = nil
+
+	for _, serial := range []int64{1, 2} {
+		if serial > 1 {
+			writeClientCert(t, certFile, keyFile, serial)
+
+			later := time.Now().Add(time.Duration(serial) * time.Second)
+			if err := os.Chtimes(certFile, later, later); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		resp, err := client.Get(context.Background(), server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+
+		if got, want := resp.Header.Get("X-Client-Serial"), big.NewInt(serial).String(); got != want {
+			t.Errorf("server saw client certificate serial %s, want %s", got, want)
+		}
+	}
+
+	// A half-written certificate keeps the previous one in use.
+	if err := os.WriteFile(certFile, []byte("-----BEGIN CERTIFICATE-----\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(3 * time.Second)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	if got, want := resp.Header.Get("X-Client-Serial"), "2"; got != want {
+		t.Errorf("server saw client certificate serial %s, want %s", got, want)
+	}
+
+	if !reloadFailed.Load() {
+		t.Error("OnReloadError was not called")
+	}
+}
+
+func writeClientCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "httpx test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newCertDER returns a new self-signed certificate in DER form.
+func newCertDER(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "httpx test pin"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der
+}
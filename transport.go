@@ -7,43 +7,384 @@ import (
 	"time"
 )
 
-// DefaultTransport returns a [*http.Transport] with optimized security and
-// performance settings for common use cases.
+// TransportOptions defines the settings used by NewTransport to build a
+// [*http.Transport].
 //
 // [*http.Transport]: https://godocs.io/net/http#Transport
-func DefaultTransport() *http.Transport {
-	return &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
-		TLSClientConfig: &tls.Config{
-			MinVersion: tls.VersionTLS12,
-			CipherSuites: []uint16{
-				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-				tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-			},
-			CurvePreferences: []tls.CurveID{
-				tls.X25519,
-				tls.CurveP256,
-				tls.CurveP521,
-				tls.CurveP384,
-			},
-			SessionTicketsDisabled: false,
-			ClientSessionCache:     tls.NewLRUClientSessionCache(64),
-		},
+type TransportOptions struct {
+	// TLSConfig is the TLS configuration used by the transport. Ignored if TLS
+	// is set.
+	TLSConfig *tls.Config
+
+	// TLS defines additional TLS settings used to build the transport's TLS
+	// configuration, such as client certificates and custom root certificate
+	// authorities.
+	TLS *TLSOptions
+
+	// Proxy is the proxy configuration used by the transport. If nil, proxies
+	// are read from the environment.
+	Proxy *ProxyConfig
+
+	// Resolver is the DNS resolver used by the dialer, such as a DNSCache. If
+	// nil, the default resolver is used.
+	Resolver Resolver
+
+	// UnixSockets maps hosts to the paths of Unix domain sockets the dialer
+	// connects to instead of resolving the host.
+	UnixSockets map[string]string
+
+	// DialTimeout is the maximum amount of time a dial will wait for a
+	// connection to complete.
+	DialTimeout time.Duration
+
+	// KeepAlive is the interval between keep-alive probes for active network
+	// connections. A negative value disables keep-alive probes.
+	KeepAlive time.Duration
+
+	// FallbackDelay is how long the dialer waits for a connection attempt
+	// before racing the next resolved address. If zero, a default delay of
+	// 300ms is used. A negative value disables racing.
+	FallbackDelay time.Duration
+
+	// FailureCooldown is how long the dialer skips an address that failed to
+	// connect while the host has other addresses. Zero disables remembering
+	// failed addresses.
+	FailureCooldown time.Duration
+
+	// TLSHandshakeTimeout is the maximum amount of time to wait for a TLS
+	// handshake. Zero means no timeout.
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout is the maximum amount of time to wait for a
+	// server's response headers after fully writing the request. Zero means no
+	// timeout.
+	ResponseHeaderTimeout time.Duration
+
+	// ExpectContinueTimeout is the maximum amount of time to wait for a
+	// server's first response headers after fully writing the request headers
+	// if the request has an "Expect: 100-continue" header.
+	ExpectContinueTimeout time.Duration
+
+	// IdleConnTimeout is the maximum amount of time an idle connection will
+	// remain idle before closing itself. Zero means no limit.
+	IdleConnTimeout time.Duration
+
+	// MaxIdleConns controls the maximum number of idle connections across all
+	// hosts. Zero means no limit.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost controls the maximum number of idle connections to
+	// keep per host.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the total number of connections per host,
+	// including connections in the dialing, active, and idle states. Zero means
+	// no limit.
+	MaxConnsPerHost int
+
+	// DisableHTTP2 specifies whether the transport should only use HTTP/1.1.
+	DisableHTTP2 bool
+
+	// DisableCompression specifies whether the transport should not request
+	// compressed responses.
+	DisableCompression bool
+
+	// DisableKeepAlives specifies whether the transport should only use a
+	// connection for a single request.
+	DisableKeepAlives bool
+}
+
+// TransportOption is a function that modifies TransportOptions.
+type TransportOption func(*TransportOptions)
+
+// DefaultTransportOptions returns the TransportOptions used by
+// DefaultTransport.
+func DefaultTransportOptions() *TransportOptions {
+	return &TransportOptions{
+		TLSConfig:             DefaultTLSConfig(),
+		DialTimeout:           30 * time.Second,
+		KeepAlive:             30 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
 		MaxIdleConns:          100,
 		MaxIdleConnsPerHost:   10,
-		IdleConnTimeout:       90 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
 		DisableCompression:    true,
-		ForceAttemptHTTP2:     true,
 	}
 }
+
+// WithTLSConfig sets the TLS configuration used by the transport.
+func WithTLSConfig(config *tls.Config) TransportOption {
+	return func(o *TransportOptions) {
+		o.TLSConfig = config
+	}
+}
+
+// WithTLSOptions sets additional TLS settings used to build the transport's
+// TLS configuration.
+func WithTLSOptions(opts *TLSOptions) TransportOption {
+	return func(o *TransportOptions) {
+		o.TLS = opts
+	}
+}
+
+// WithProxy sets the proxy configuration used by the transport.
+func WithProxy(config *ProxyConfig) TransportOption {
+	return func(o *TransportOptions) {
+		o.Proxy = config
+	}
+}
+
+// WithResolver sets the DNS resolver used by the transport's dialer. Use a
+// DNSCache to cache lookups in-process.
+func WithResolver(resolver Resolver) TransportOption {
+	return func(o *TransportOptions) {
+		o.Resolver = resolver
+	}
+}
+
+// WithUnixSocket maps a host to the path of a Unix domain socket, so that
+// requests to the host are sent over the socket instead of TCP.
+func WithUnixSocket(host, path string) TransportOption {
+	return func(o *TransportOptions) {
+		if o.UnixSockets == nil {
+			o.UnixSockets = make(map[string]string)
+		}
+
+		o.UnixSockets[host] = path
+	}
+}
+
+// WithDialTimeout sets the maximum amount of time the transport's dialer waits
+// for a connection to complete.
+func WithDialTimeout(timeout time.Duration) TransportOption {
+	return func(o *TransportOptions) {
+		o.DialTimeout = timeout
+	}
+}
+
+// WithKeepAliveInterval sets the interval between keep-alive probes for the
+// transport's connections. A negative value disables keep-alive probes.
+func WithKeepAliveInterval(interval time.Duration) TransportOption {
+	return func(o *TransportOptions) {
+		o.KeepAlive = interval
+	}
+}
+
+// WithFallbackDelay sets how long the transport's dialer waits for a
+// connection attempt before racing the next resolved address. A negative value
+// disables racing.
+func WithFallbackDelay(delay time.Duration) TransportOption {
+	return func(o *TransportOptions) {
+		o.FallbackDelay = delay
+	}
+}
+
+// WithFailureCooldown sets how long the transport's dialer skips an address
+// that failed to connect while the host has other addresses.
+func WithFailureCooldown(cooldown time.Duration) TransportOption {
+	return func(o *TransportOptions) {
+		o.FailureCooldown = cooldown
+	}
+}
+
+// WithTLSHandshakeTimeout sets the maximum amount of time the transport waits
+// for a TLS handshake.
+func WithTLSHandshakeTimeout(timeout time.Duration) TransportOption {
+	return func(o *TransportOptions) {
+		o.TLSHandshakeTimeout = timeout
+	}
+}
+
+// WithResponseHeaderTimeout sets the maximum amount of time the transport
+// waits for a server's response headers after fully writing the request.
+func WithResponseHeaderTimeout(timeout time.Duration) TransportOption {
+	return func(o *TransportOptions) {
+		o.ResponseHeaderTimeout = timeout
+	}
+}
+
+// WithExpectContinueTimeout sets the maximum amount of time the transport
+// waits for a server's first response headers when the request has an
+// "Expect: 100-continue" header.
+func WithExpectContinueTimeout(timeout time.Duration) TransportOption {
+	return func(o *TransportOptions) {
+		o.ExpectContinueTimeout = timeout
+	}
+}
+
+// WithIdleConnTimeout sets the maximum amount of time an idle connection
+// remains open.
+func WithIdleConnTimeout(timeout time.Duration) TransportOption {
+	return func(o *TransportOptions) {
+		o.IdleConnTimeout = timeout
+	}
+}
+
+// WithMaxIdleConns sets the maximum number of idle connections across all
+// hosts.
+func WithMaxIdleConns(n int) TransportOption {
+	return func(o *TransportOptions) {
+		o.MaxIdleConns = n
+	}
+}
+
+// WithMaxIdleConnsPerHost sets the maximum number of idle connections kept per
+// host.
+func WithMaxIdleConnsPerHost(n int) TransportOption {
+	return func(o *TransportOptions) {
+		o.MaxIdleConnsPerHost = n
+	}
+}
+
+// WithMaxConnsPerHost sets the maximum number of connections per host,
+// including connections in the dialing, active, and idle states.
+func WithMaxConnsPerHost(n int) TransportOption {
+	return func(o *TransportOptions) {
+		o.MaxConnsPerHost = n
+	}
+}
+
+// WithHTTP2 enables or disables HTTP/2 support.
+func WithHTTP2(enabled bool) TransportOption {
+	return func(o *TransportOptions) {
+		o.DisableHTTP2 = !enabled
+	}
+}
+
+// WithCompression enables or disables transparent response compression.
+func WithCompression(enabled bool) TransportOption {
+	return func(o *TransportOptions) {
+		o.DisableCompression = !enabled
+	}
+}
+
+// WithKeepAlives enables or disables connection reuse.
+func WithKeepAlives(enabled bool) TransportOption {
+	return func(o *TransportOptions) {
+		o.DisableKeepAlives = !enabled
+	}
+}
+
+// NewTransport returns a [*http.Transport] built from DefaultTransportOptions
+// and the given options.
+//
+// [*http.Transport]: https://godocs.io/net/http#Transport
+func NewTransport(opts ...TransportOption) (*http.Transport, error) {
+	options := DefaultTransportOptions()
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return options.Transport()
+}
+
+// Transport builds a [*http.Transport] from the options.
+//
+// [*http.Transport]: https://godocs.io/net/http#Transport
+func (o *TransportOptions) Transport() (*http.Transport, error) {
+	tlsConfig := o.TLSConfig
+
+	if o.TLS != nil {
+		config, err := o.TLS.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig = config
+	}
+
+	return o.transport(tlsConfig), nil
+}
+
+// DefaultTransport returns a [*http.Transport] with optimized security and
+// performance settings for common use cases.
+//
+// [*http.Transport]: https://godocs.io/net/http#Transport
+func DefaultTransport() *http.Transport {
+	options := DefaultTransportOptions()
+
+	return options.transport(options.TLSConfig)
+}
+
+// DefaultTLSConfig returns a [*tls.Config] with the secure defaults used by
+// DefaultTransport.
+//
+// [*tls.Config]: https://godocs.io/crypto/tls#Config
+func DefaultTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{
+			tls.X25519,
+			tls.CurveP256,
+			tls.CurveP521,
+			tls.CurveP384,
+		},
+		SessionTicketsDisabled: false,
+		ClientSessionCache:     tls.NewLRUClientSessionCache(64),
+	}
+}
+
+// transport builds a [*http.Transport] from the options using the given TLS
+// configuration.
+//
+// [*http.Transport]: https://godocs.io/net/http#Transport
+func (o *TransportOptions) transport(tlsConfig *tls.Config) *http.Transport {
+	dialContext := (&net.Dialer{
+		Timeout:       o.DialTimeout,
+		KeepAlive:     o.KeepAlive,
+		FallbackDelay: o.FallbackDelay,
+	}).DialContext
+
+	// The package's Dialer is only used when one of its features is enabled,
+	// so that transports keep the standard library's dialing behavior by
+	// default.
+	if o.Resolver != nil || len(o.UnixSockets) > 0 || o.FailureCooldown > 0 {
+		dialContext = (&Dialer{
+			Resolver:        o.Resolver,
+			Timeout:         o.DialTimeout,
+			KeepAlive:       o.KeepAlive,
+			FallbackDelay:   o.FallbackDelay,
+			FailureCooldown: o.FailureCooldown,
+			UnixSockets:     o.UnixSockets,
+		}).DialContext
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   o.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: o.ResponseHeaderTimeout,
+		ExpectContinueTimeout: o.ExpectContinueTimeout,
+		IdleConnTimeout:       o.IdleConnTimeout,
+		MaxIdleConns:          o.MaxIdleConns,
+		MaxIdleConnsPerHost:   o.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       o.MaxConnsPerHost,
+		DisableCompression:    o.DisableCompression,
+		DisableKeepAlives:     o.DisableKeepAlives,
+		ForceAttemptHTTP2:     !o.DisableHTTP2,
+	}
+
+	if o.DisableHTTP2 {
+		// A non-nil, empty map prevents the transport from negotiating HTTP/2.
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	if o.Proxy != nil {
+		o.Proxy.Apply(transport)
+	}
+
+	RegisterUnixProtocol(transport)
+
+	return transport
+}
@@ -0,0 +1,137 @@
+package httpx_test
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+)
+
+func TestNewTransport(t *testing.T) {
+	t.Parallel()
+
+	proxy := &httpx.ProxyConfig{HTTPProxy: mustParseURL(t, "http://proxy.example.com:8080")}
+
+	transport, err := httpx.NewTransport(
+		httpx.WithMaxIdleConns(50),
+		httpx.WithMaxIdleConnsPerHost(25),
+		httpx.WithMaxConnsPerHost(40),
+		httpx.WithIdleConnTimeout(time.Minute),
+		httpx.WithTLSHandshakeTimeout(5*time.Second),
+		httpx.WithResponseHeaderTimeout(15*time.Second),
+		httpx.WithExpectContinueTimeout(2*time.Second),
+		httpx.WithCompression(true),
+		httpx.WithKeepAlives(false),
+		httpx.WithProxy(proxy),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if transport.MaxIdleConns != 50 || transport.MaxIdleConnsPerHost != 25 || transport.MaxConnsPerHost != 40 {
+		t.Errorf("got pool limits %d/%d/%d, want 50/25/40",
+			transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+
+	if transport.IdleConnTimeout != time.Minute {
+		t.Errorf("got idle timeout %s, want %s", transport.IdleConnTimeout, time.Minute)
+	}
+
+	if transport.TLSHandshakeTimeout != 5*time.Second || transport.ResponseHeaderTimeout != 15*time.Second ||
+		transport.ExpectContinueTimeout != 2*time.Second {
+		t.Errorf("got timeouts %s/%s/%s, want 5s/15s/2s",
+			transport.TLSHandshakeTimeout, transport.ResponseHeaderTimeout, transport.ExpectContinueTimeout)
+	}
+
+	if transport.DisableCompression {
+		t.Error("expected compression to be enabled")
+	}
+
+	if !transport.DisableKeepAlives {
+		t.Error("expected keep-alives to be disabled")
+	}
+
+	if !transport.ForceAttemptHTTP2 {
+		t.Error("expected HTTP/2 to be enabled")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", http.NoBody)
+
+	got, err := transport.Proxy(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.String() != proxy.HTTPProxy.String() {
+		t.Errorf("got proxy %v, want %v", got, proxy.HTTPProxy)
+	}
+}
+
+func TestNewTransport_HTTP2(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    string
+	}{
+		{name: "enabled", enabled: true, want: "HTTP/2.0"},
+		{name: "disabled", enabled: false, want: "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := httpx.DefaultTLSConfig()
+			config.RootCAs = x509.NewCertPool()
+			config.RootCAs.AddCert(server.Certificate())
+
+			transport, err := httpx.NewTransport(httpx.WithTLSConfig(config), httpx.WithHTTP2(tt.enabled))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := httpx.NewClient()
+			client.Transport = transport
+			client.RetryPolicy = nil
+
+			resp, err := client.Get(context.Background(), server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("X-Proto"); got != tt.want {
+				t.Errorf("got protocol %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTransport_TLSOptions(t *testing.T) {
+	t.Parallel()
+
+	_, err := httpx.NewTransport(httpx.WithTLSOptions(&httpx.TLSOptions{
+		RootCAFiles: []string{"testdata/does-not-exist.pem"},
+	}))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+}
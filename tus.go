@@ -0,0 +1,188 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// _tusVersion is the version of the tus protocol implemented by
+	// TusProtocol.
+	_tusVersion string = "1.0.0"
+
+	// _mediaTypeOffsetOctetStream is the Content-Type of tus chunk requests.
+	_mediaTypeOffsetOctetStream string = "application/offset+octet-stream"
+)
+
+// TusProtocol is an UploadProtocol implementing the core and creation
+// extension of the tus resumable upload protocol, version 1.0.0.
+//
+// Uploads of unknown size require the server to support the
+// creation-defer-length extension.
+type TusProtocol struct {
+	// Metadata is sent in the Upload-Metadata header when creating uploads.
+	Metadata map[string]string
+
+	// Endpoint is the URL uploads are created at.
+	Endpoint string
+}
+
+// Compile-time check to ensure TusProtocol implements the UploadProtocol
+// interface.
+var _ UploadProtocol = (*TusProtocol)(nil)
+
+// NewTusProtocol returns a new TusProtocol creating uploads at the given
+// endpoint.
+func NewTusProtocol(endpoint string) *TusProtocol {
+	return &TusProtocol{
+		Endpoint: endpoint,
+	}
+}
+
+// Create creates an upload at the endpoint and sets the upload's URL to its
+// location.
+func (p *TusProtocol) Create(ctx context.Context, client *Client, upload *Upload) error {
+	req, err := p.newRequest(ctx, http.MethodPost, p.Endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	if upload.Size >= 0 {
+		req.Header.Set("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	} else {
+		req.Header.Set("Upload-Defer-Length", "1")
+	}
+
+	if metadata := p.metadata(); metadata != "" {
+		req.Header.Set("Upload-Metadata", metadata)
+	}
+
+	resp, err := client.Do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer client.discardResponse(resp)
+
+	if resp.StatusCode != http.StatusCreated {
+		return uploadStatusError(resp)
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	upload.URL = location.String()
+
+	return nil
+}
+
+// Offset returns the Upload-Offset of the upload reported by the server.
+func (p *TusProtocol) Offset(ctx context.Context, client *Client, upload *Upload) (int64, error) {
+	req, err := p.newRequest(ctx, http.MethodHead, upload.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// A cached offset would make a resumed upload re-send or skip bytes.
+	req.Header.Set("Cache-Control", "no-store")
+
+	resp, err := client.Do(ctx, req)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	defer client.discardResponse(resp)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return 0, uploadStatusError(resp)
+	}
+
+	return parseUploadOffset(resp)
+}
+
+// ChunkRequest returns a PATCH request appending chunk to the upload. The last
+// chunk of an upload of unknown size declares the upload's length.
+func (p *TusProtocol) ChunkRequest(ctx context.Context, upload *Upload, chunk []byte, last bool) (*http.Request, error) {
+	req, err := p.newRequest(ctx, http.MethodPatch, upload.URL, chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", _mediaTypeOffsetOctetStream)
+	req.Header.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+
+	if last && upload.Size < 0 {
+		req.Header.Set("Upload-Length", strconv.FormatInt(upload.Offset+int64(len(chunk)), 10))
+	}
+
+	return req, nil
+}
+
+// ChunkDone checks that the Upload-Offset returned by the server matches the
+// end of the chunk.
+func (*TusProtocol) ChunkDone(upload *Upload, resp *http.Response, chunk []byte) error {
+	offset, err := parseUploadOffset(resp)
+	if err != nil {
+		return err
+	}
+
+	if want := upload.Offset + int64(len(chunk)); offset != want {
+		return fmt.Errorf("%w: server reported %d, want %d", ErrUploadOffsetMismatch, offset, want)
+	}
+
+	return nil
+}
+
+// Complete does nothing, as tus uploads complete once their last chunk is
+// uploaded.
+func (*TusProtocol) Complete(context.Context, *Client, *Upload) error {
+	return nil
+}
+
+// newRequest returns a new tus request with the given body, if any.
+func (*TusProtocol) newRequest(ctx context.Context, method, uri string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	req.Header.Set("Tus-Resumable", _tusVersion)
+
+	return req, nil
+}
+
+// metadata returns the Upload-Metadata header value for the protocol's
+// metadata, with keys sorted.
+func (p *TusProtocol) metadata() string {
+	keys := make([]string, 0, len(p.Metadata))
+
+	for key := range p.Metadata {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(p.Metadata[key])))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// parseUploadOffset returns the Upload-Offset header of a tus response.
+func parseUploadOffset(resp *http.Response) (int64, error) {
+	offset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("%w: invalid Upload-Offset %q", ErrUploadOffsetMismatch, resp.Header.Get("Upload-Offset"))
+	}
+
+	return offset, nil
+}
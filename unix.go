@@ -0,0 +1,120 @@
+package httpx
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrInvalidUnixURL is returned when an http+unix URL does not separate the
+// socket path from the request path.
+const ErrInvalidUnixURL xerrors.Error = "invalid http+unix URL: missing ':' between socket and request path"
+
+// SchemeHTTPUnix is the URL scheme for HTTP requests sent over a Unix domain
+// socket. The URL has no host and its path is the socket path followed by a
+// colon and the request path, as in
+// http+unix:///var/run/docker.sock:/v1.43/info.
+const SchemeHTTPUnix string = "http+unix"
+
+// _unixHost is the Host header sent with requests over a Unix domain socket.
+const _unixHost string = "localhost"
+
+// RegisterUnixProtocol registers the http+unix scheme on the given transport,
+// allowing it to send requests over the Unix domain socket named in the URL.
+// Transports built by NewTransport have it registered already.
+//
+// Must be called after the transport is configured, as requests over Unix
+// sockets use a copy of the transport that is taken at registration.
+func RegisterUnixProtocol(t *http.Transport) {
+	t.RegisterProtocol(SchemeHTTPUnix, newUnixTransport(t))
+}
+
+// newUnixTransport returns a unixTransport that sends requests with a copy of
+// the given transport.
+func newUnixTransport(t *http.Transport) *unixTransport {
+	unix := t.Clone()
+	unix.Proxy = nil
+	unix.OnProxyConnectResponse = nil
+	unix.DialTLSContext = nil
+	unix.DialContext = func(ctx context.Context, _, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		path, err := hex.DecodeString(host)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		return dialUnix(ctx, string(path))
+	}
+
+	return &unixTransport{transport: unix}
+}
+
+// unixTransport is a RoundTripper that sends http+unix requests over a Unix
+// domain socket.
+type unixTransport struct {
+	// transport is the transport used to send the rewritten requests.
+	transport *http.Transport
+}
+
+// RoundTrip implements the http.RoundTripper interface. The request is sent as
+// a plain HTTP request to a host encoding the socket path, so that each socket
+// gets its own connection pool.
+func (u *unixTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	socket, path, ok := strings.Cut(req.URL.Path, ":")
+	if !ok || socket == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidUnixURL, req.URL.Redacted())
+	}
+
+	clone := req.Clone(req.Context())
+	clone.URL.Scheme = "http"
+	clone.URL.Host = hex.EncodeToString([]byte(socket))
+	clone.URL.Path = path
+	clone.URL.RawPath = ""
+
+	if req.Host == "" {
+		clone.Host = _unixHost
+	}
+
+	resp, err := u.transport.RoundTrip(clone)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // http.Client inspects the error to report timeouts.
+	}
+
+	resp.Request = req
+
+	return resp, nil
+}
+
+// requestHost returns the host a request is sent to, as used to key circuit
+// breakers, metrics, throttling and connection statistics. Requests to
+// http+unix URLs have no host, so the path of their socket is used instead.
+func requestHost(req *http.Request) string {
+	if req.URL.Scheme == SchemeHTTPUnix {
+		socket, _, _ := strings.Cut(req.URL.Path, ":")
+
+		return socket
+	}
+
+	return req.URL.Host
+}
+
+// dialUnix connects to the Unix domain socket at the given path.
+func dialUnix(ctx context.Context, path string) (net.Conn, error) {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,175 @@
+package httpx_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+func TestClient_UnixSocket(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// host is mapped to the socket if set. Otherwise, an http+unix URL is
+		// used.
+		host     string
+		wantHost string
+	}{
+		{
+			name:     "http+unix URL",
+			wantHost: "localhost",
+		},
+		{
+			name:     "host mapped to socket",
+			host:     "sidecar",
+			wantHost: "sidecar",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests atomic.Int32
+
+			socket := newUnixServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Host", r.Host)
+				w.Header().Set("X-User-Agent", r.UserAgent())
+
+				if requests.Add(1) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			var (
+				opts   []httpx.TransportOption
+				target = httpx.SchemeHTTPUnix + "://" + socket + ":/info"
+			)
+
+			if tt.host != "" {
+				opts = append(opts, httpx.WithUnixSocket(tt.host, socket))
+				target = "http://" + tt.host + "/info"
+			}
+
+			transport, err := httpx.NewTransport(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := httpx.NewClient()
+			client.Transport = transport
+			client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+			client.RetryPolicy.MinRetryDelay = time.Millisecond
+			client.RetryPolicy.MaxRetryDelay = time.Millisecond
+
+			resp, err := client.Get(context.Background(), target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+
+			if got := requests.Load(); got != 2 {
+				t.Errorf("server received %d requests, want 2", got)
+			}
+
+			if got := resp.Header.Get("X-Host"); got != tt.wantHost {
+				t.Errorf("got host %q, want %q", got, tt.wantHost)
+			}
+
+			if got, want := resp.Header.Get("X-User-Agent"), httpx.DefaultUserAgent().String(); got != want {
+				t.Errorf("got user agent %q, want %q", got, want)
+			}
+
+			if got := resp.Request.URL.String(); got != target {
+				t.Errorf("got request URL %q, want %q", got, target)
+			}
+		})
+	}
+}
+
+func TestClient_UnixSocketHost(t *testing.T) {
+	t.Parallel()
+
+	socket := newUnixServer(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	transport, err := httpx.NewTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := httpx.NewClient()
+	client.Transport = transport
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+	client.RetryPolicy = nil
+	client.CircuitBreaker = httpx.DefaultCircuitBreaker()
+	client.CircuitBreaker.MinRequests = 2
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(context.Background(), httpx.SchemeHTTPUnix+"://"+socket+":/info")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+	}
+
+	if got := client.CircuitBreaker.State(socket); got != httpx.CircuitOpen {
+		t.Errorf("got circuit state %v for the socket, want %v", got, httpx.CircuitOpen)
+	}
+
+	if got := client.ConnStats()[socket].Dialed; got != 1 {
+		t.Errorf("got %d dialed connections for the socket, want 1", got)
+	}
+}
+
+// newUnixServer starts an HTTP server listening on a Unix domain socket and
+// returns the socket's path.
+func newUnixServer(t *testing.T, handler http.Handler) string {
+	t.Helper()
+
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed.
+	dir, err := os.MkdirTemp("", "httpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "httpx.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("cannot listen on Unix socket: %v", err)
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: time.Second,
+	}
+
+	go server.Serve(listener)
+
+	t.Cleanup(func() { server.Close() })
+
+	return socket
+}
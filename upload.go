@@ -0,0 +1,301 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrUploadOffsetMismatch is returned when the server reports an upload offset
+// other than the one expected after uploading a chunk.
+const ErrUploadOffsetMismatch xerrors.Error = "upload offset mismatch"
+
+// _defaultUploadChunkSize is the default size of the chunks uploaded by an
+// Uploader.
+const _defaultUploadChunkSize int64 = 8 << 20
+
+// Upload is the state of a chunked upload. It can be stored and passed to
+// Uploader.Resume to continue an interrupted upload.
+type Upload struct {
+	// URL is the location of the upload, as set by the protocol.
+	URL string
+
+	// ID identifies the upload, for protocols that need it, such as the upload
+	// ID of S3 multipart uploads.
+	ID string
+
+	// Parts holds the chunks uploaded so far, for protocols that need them to
+	// complete the upload.
+	Parts []UploadPart
+
+	// Offset is the number of bytes uploaded so far.
+	Offset int64
+
+	// Size is the total size of the upload, or -1 if unknown.
+	Size int64
+}
+
+// UploadPart is a chunk of an upload.
+type UploadPart struct {
+	// ETag is the entity tag returned by the server for the chunk, if any.
+	ETag string
+
+	// Number is the position of the chunk in the upload, starting at one.
+	Number int
+
+	// Size is the size of the chunk.
+	Size int64
+}
+
+// UploadProtocol implements a chunked, resumable upload protocol.
+type UploadProtocol interface {
+	// Create starts a new upload of upload.Size bytes, or of unknown size if
+	// negative, and sets the fields of the upload the protocol needs.
+	Create(ctx context.Context, client *Client, upload *Upload) error
+
+	// Offset returns the number of bytes of the upload received by the
+	// server, from which the upload is resumed.
+	Offset(ctx context.Context, client *Client, upload *Upload) (int64, error)
+
+	// ChunkRequest returns a request uploading chunk at upload.Offset. last
+	// specifies whether the chunk is the last one of the upload. The last
+	// chunk is empty if the upload is empty or its size was unknown and is
+	// only declared once everything was uploaded.
+	ChunkRequest(ctx context.Context, upload *Upload, chunk []byte, last bool) (*http.Request, error)
+
+	// ChunkDone records the successful response to a chunk request before
+	// the upload's offset is advanced past the chunk.
+	ChunkDone(upload *Upload, resp *http.Response, chunk []byte) error
+
+	// Complete finishes the upload once every chunk was uploaded.
+	Complete(ctx context.Context, client *Client, upload *Upload) error
+}
+
+// Uploader uploads data in chunks through an UploadProtocol, such as tus or S3
+// multipart uploads.
+//
+// Only one chunk is held in memory at a time. Failed chunks are retried
+// individually according to the client's RetryPolicy: chunks sent with
+// idempotent methods are retried by Client.Do, while others are retried by the
+// Uploader after asking the server how much of the chunk it received.
+type Uploader struct {
+	// client is the client used to send requests.
+	client *Client
+
+	// Protocol is the upload protocol.
+	Protocol UploadProtocol
+
+	// Progress, if not nil, is called after every chunk is uploaded with the
+	// number of bytes uploaded so far and the total size of the upload, or -1
+	// if unknown.
+	Progress func(uploaded, total int64)
+
+	// ChunkSize is the size of the chunks uploaded.
+	ChunkSize int64
+}
+
+// NewUploader returns a new Uploader sending requests through the given
+// client using the given protocol.
+func NewUploader(client *Client, protocol UploadProtocol) *Uploader {
+	return &Uploader{
+		client:    client,
+		Protocol:  protocol,
+		ChunkSize: _defaultUploadChunkSize,
+	}
+}
+
+// Upload uploads size bytes read from r, or everything r returns if size is
+// negative. It returns the state of the upload, which can be passed to Resume
+// if the upload fails after being created.
+func (u *Uploader) Upload(ctx context.Context, r io.Reader, size int64) (*Upload, error) {
+	upload := &Upload{
+		Size: size,
+	}
+
+	if err := u.Protocol.Create(ctx, u.client, upload); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if size >= 0 {
+		r = io.LimitReader(r, size)
+	}
+
+	return upload, u.upload(ctx, upload, r)
+}
+
+// Resume continues an interrupted upload from the offset reported by the
+// server, seeking r to that offset.
+func (u *Uploader) Resume(ctx context.Context, upload *Upload, r io.ReadSeeker) error {
+	offset, err := u.Protocol.Offset(ctx, u.client, upload)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if _, err = r.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	upload.Offset = offset
+
+	var reader io.Reader = r
+
+	if upload.Size >= 0 {
+		reader = io.LimitReader(r, upload.Size-offset)
+	}
+
+	return u.upload(ctx, upload, reader)
+}
+
+// upload reads chunks from r, uploads them, and completes the upload.
+func (u *Uploader) upload(ctx context.Context, upload *Upload, r io.Reader) error {
+	var (
+		reader = bufio.NewReader(r)
+		chunk  = make([]byte, u.ChunkSize)
+	)
+
+	for {
+		n, last, err := readChunk(reader, chunk)
+		if err != nil {
+			return err
+		}
+
+		if n > 0 || (last && (upload.Offset == 0 || upload.Size < 0)) {
+			if err = u.sendChunk(ctx, upload, chunk[:n], last); err != nil {
+				return err
+			}
+
+			if u.Progress != nil {
+				u.Progress(upload.Offset, upload.Size)
+			}
+		}
+
+		if last {
+			break
+		}
+	}
+
+	upload.Size = upload.Offset
+
+	if err := u.Protocol.Complete(ctx, u.client, upload); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// sendChunk uploads a chunk, retrying it from the offset reported by the
+// server if it fails.
+func (u *Uploader) sendChunk(ctx context.Context, upload *Upload, chunk []byte, last bool) error {
+	start := upload.Offset
+
+	for attempt := 0; ; attempt++ {
+		sent := upload.Offset - start
+
+		req, err := u.Protocol.ChunkRequest(ctx, upload, chunk[sent:], last)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		resp, err := u.client.Do(ctx, req)
+		if err == nil && resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+			err = u.Protocol.ChunkDone(upload, resp, chunk[sent:])
+
+			u.client.discardResponse(resp)
+
+			if err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			upload.Offset = start + int64(len(chunk))
+
+			return nil
+		}
+
+		if !u.retryChunk(req, resp, err, attempt) {
+			u.client.discardResponse(resp)
+
+			if err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			return uploadStatusError(resp)
+		}
+
+		u.client.debugf("[DEBUG] Retrying upload chunk at byte %d: %s %s", upload.Offset, req.Method, req.URL)
+
+		delay := u.client.RetryPolicy.RetryAfter(resp)
+
+		u.client.discardResponse(resp)
+
+		if err = u.client.RetryPolicy.sleep(ctx, delay); err != nil {
+			return err
+		}
+
+		offset, err := u.Protocol.Offset(ctx, u.client, upload)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if offset < start || offset > start+int64(len(chunk)) {
+			return fmt.Errorf("%w: server reported %d, want between %d and %d",
+				ErrUploadOffsetMismatch, offset, start, start+int64(len(chunk)))
+		}
+
+		upload.Offset = offset
+	}
+}
+
+// retryChunk reports whether a chunk request that failed with the given
+// response or error should be retried by the Uploader. Requests retryable by
+// Client.Do were already retried.
+func (u *Uploader) retryChunk(req *http.Request, resp *http.Response, err error, attempt int) bool {
+	policy := u.client.RetryPolicy
+
+	if policy == nil || policy.IsRetryable(req) || attempt >= policy.MaxRetries-1 {
+		return false
+	}
+
+	if err != nil {
+		return policy.ShouldRetryError(err)
+	}
+
+	return policy.ShouldRetry(resp)
+}
+
+// readChunk fills chunk from r and reports how many bytes were read and
+// whether they are the last ones r returns.
+func readChunk(r *bufio.Reader, chunk []byte) (int, bool, error) {
+	n, err := io.ReadFull(r, chunk)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return n, true, nil
+	}
+
+	if err != nil {
+		return n, false, fmt.Errorf("%w", err)
+	}
+
+	if _, err = r.Peek(1); errors.Is(err, io.EOF) {
+		return n, true, nil
+	} else if err != nil {
+		return n, false, fmt.Errorf("%w", err)
+	}
+
+	return n, false, nil
+}
+
+// uploadStatusError returns an error describing an unexpected response status
+// to an upload request.
+func uploadStatusError(resp *http.Response) error {
+	return &Error{
+		URL:        resp.Request.URL,
+		Method:     resp.Request.Method,
+		Message:    "unexpected status for upload",
+		StatusText: http.StatusText(resp.StatusCode),
+		StatusCode: resp.StatusCode,
+	}
+}
@@ -0,0 +1,384 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/httpx-go"
+	"golang.org/x/time/rate"
+)
+
+// tusServer is a minimal tus server that stores a single upload and fails the
+// first PATCH request at or after failAt after receiving part of its body.
+type tusServer struct {
+	data     []byte
+	length   string
+	failAt   int
+	failed   bool
+	patches  int
+	mu       sync.Mutex
+	metadata string
+}
+
+func (s *tusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Tus-Resumable") != "1.0.0" {
+		w.WriteHeader(http.StatusPreconditionFailed)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.length = r.Header.Get("Upload-Length")
+		s.metadata = r.Header.Get("Upload-Metadata")
+
+		w.Header().Set("Location", "/files/1")
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodHead:
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.data)))
+		w.Header().Set("Cache-Control", "no-store")
+	case http.MethodPatch:
+		s.patches++
+
+		if r.Header.Get("Upload-Offset") != strconv.Itoa(len(s.data)) {
+			w.WriteHeader(http.StatusConflict)
+
+			return
+		}
+
+		if length := r.Header.Get("Upload-Length"); length != "" {
+			s.length = length
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		if !s.failed && len(s.data)+len(body) > s.failAt && s.failAt > 0 {
+			s.failed = true
+			s.data = append(s.data, body[:len(body)/2]...)
+
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		s.data = append(s.data, body...)
+
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(s.data)))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestUploader_Tus(t *testing.T) {
+	t.Parallel()
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	tests := []struct {
+		name        string
+		size        int64
+		failAt      int
+		wantLength  string
+		wantPatches int
+	}{
+		{
+			name:        "known size",
+			size:        int64(len(content)),
+			wantLength:  "1000",
+			wantPatches: 4,
+		},
+		{
+			name:        "unknown size",
+			size:        -1,
+			wantLength:  "1000",
+			wantPatches: 4,
+		},
+		{
+			name:        "failed chunk is resumed",
+			size:        int64(len(content)),
+			failAt:      300,
+			wantLength:  "1000",
+			wantPatches: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tus := &tusServer{failAt: tt.failAt}
+
+			server := httptest.NewServer(tus)
+			t.Cleanup(server.Close)
+
+			client := httpx.NewClient()
+			client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+			client.RetryPolicy.MinRetryDelay = time.Millisecond
+			client.RetryPolicy.MaxRetryDelay = time.Millisecond
+
+			protocol := httpx.NewTusProtocol(server.URL + "/files")
+			protocol.Metadata = map[string]string{"filename": "report.txt"}
+
+			var progress []int64
+
+			uploader := httpx.NewUploader(client, protocol)
+			uploader.ChunkSize = 256
+			uploader.Progress = func(uploaded, _ int64) {
+				progress = append(progress, uploaded)
+			}
+
+			upload, err := uploader.Upload(context.Background(), bytes.NewReader(content), tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if upload.URL != server.URL+"/files/1" {
+				t.Errorf("got upload URL %q, want %q", upload.URL, server.URL+"/files/1")
+			}
+
+			if upload.Offset != int64(len(content)) || upload.Size != int64(len(content)) {
+				t.Errorf("got offset %d and size %d, want %d", upload.Offset, upload.Size, len(content))
+			}
+
+			tus.mu.Lock()
+			defer tus.mu.Unlock()
+
+			if !bytes.Equal(tus.data, content) {
+				t.Errorf("uploaded content does not match")
+			}
+
+			if tus.length != tt.wantLength {
+				t.Errorf("got Upload-Length %q, want %q", tus.length, tt.wantLength)
+			}
+
+			if tus.metadata != "filename cmVwb3J0LnR4dA==" {
+				t.Errorf("got Upload-Metadata %q", tus.metadata)
+			}
+
+			if tus.patches != tt.wantPatches {
+				t.Errorf("got %d PATCH requests, want %d", tus.patches, tt.wantPatches)
+			}
+
+			if want := []int64{256, 512, 768, 1000}; fmt.Sprint(progress) != fmt.Sprint(want) {
+				t.Errorf("got progress %v, want %v", progress, want)
+			}
+		})
+	}
+}
+
+func TestTusProtocol_OffsetBypassesCache(t *testing.T) {
+	t.Parallel()
+
+	var offset int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Upload-Offset", strconv.Itoa(offset))
+
+		offset += 100
+	}))
+	t.Cleanup(server.Close)
+
+	client := httpx.NewClientWithCache(nil)
+	client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	protocol := httpx.NewTusProtocol(server.URL + "/files")
+	upload := &httpx.Upload{URL: server.URL + "/files/1"}
+
+	for _, want := range []int64{0, 100} {
+		got, err := protocol.Offset(context.Background(), client, upload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != want {
+			t.Errorf("got offset %d, want %d", got, want)
+		}
+	}
+}
+
+// s3Server is a minimal server for S3 multipart uploads that fails the first
+// attempt at uploading the second part.
+type s3Server struct {
+	parts     map[string][]byte
+	object    []byte
+	signed    int
+	requests  int
+	failed    bool
+	errorBody bool
+	mu        sync.Mutex
+}
+
+func (s *s3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+
+	if r.Header.Get("Authorization") == "signed" {
+		s.signed++
+	}
+
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.RawQuery == "uploads":
+		fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`)
+	case r.Method == http.MethodPut && query.Get("uploadId") == "upload-1":
+		number := query.Get("partNumber")
+
+		if number == "2" && !s.failed {
+			s.failed = true
+
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		s.parts[number] = body
+
+		w.Header().Set("ETag", `"etag-`+number+`"`)
+	case r.Method == http.MethodPost && query.Get("uploadId") == "upload-1":
+		if s.errorBody {
+			fmt.Fprint(w, `<Error><Code>InternalError</Code><Message>try again</Message></Error>`)
+
+			return
+		}
+
+		var complete struct {
+			Parts []struct {
+				ETag       string
+				PartNumber int
+			} `xml:"Part"`
+		}
+
+		if err := xml.NewDecoder(r.Body).Decode(&complete); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		for i, part := range complete.Parts {
+			number := strconv.Itoa(part.PartNumber)
+
+			if part.PartNumber != i+1 || part.ETag != `"etag-`+number+`"` {
+				w.WriteHeader(http.StatusBadRequest)
+
+				return
+			}
+
+			s.object = append(s.object, s.parts[number]...)
+		}
+
+		fmt.Fprint(w, `<CompleteMultipartUploadResult><ETag>"object"</ETag></CompleteMultipartUploadResult>`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestUploader_S3(t *testing.T) {
+	t.Parallel()
+
+	content := strings.Repeat("0123456789", 100)
+
+	tests := []struct {
+		name      string
+		errorBody bool
+		wantErr   error
+	}{
+		{
+			name: "upload",
+		},
+		{
+			name:      "error document on completion",
+			errorBody: true,
+			wantErr:   httpx.ErrS3Upload,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s3 := &s3Server{
+				parts:     make(map[string][]byte),
+				errorBody: tt.errorBody,
+			}
+
+			server := httptest.NewServer(s3)
+			t.Cleanup(server.Close)
+
+			client := httpx.NewClient()
+			client.RateLimiter = rate.NewLimiter(rate.Inf, 1)
+			client.RetryPolicy.MinRetryDelay = time.Millisecond
+			client.RetryPolicy.MaxRetryDelay = time.Millisecond
+
+			protocol := httpx.NewS3Protocol(server.URL + "/bucket/object")
+			protocol.Sign = func(req *http.Request) error {
+				req.Header.Set("Authorization", "signed")
+
+				return nil
+			}
+
+			uploader := httpx.NewUploader(client, protocol)
+			uploader.ChunkSize = 400
+
+			upload, err := uploader.Upload(context.Background(), strings.NewReader(content), -1)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if upload.ID != "upload-1" || len(upload.Parts) != 3 {
+				t.Errorf("got upload ID %q with %d parts, want upload-1 with 3", upload.ID, len(upload.Parts))
+			}
+
+			s3.mu.Lock()
+			defer s3.mu.Unlock()
+
+			if string(s3.object) != content {
+				t.Errorf("uploaded object does not match")
+			}
+
+			// Create, three parts, one retried part, and complete.
+			if s3.requests != 6 || s3.signed != 6 {
+				t.Errorf("got %d requests with %d signed, want 6", s3.requests, s3.signed)
+			}
+		})
+	}
+}